@@ -0,0 +1,264 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	e "github.com/tuboc/chip8/emulator"
+)
+
+var commands = map[string]func(args []string){
+	"diff":      diffCommand,
+	"tracediff": tracediffCommand,
+	"profile":   profileCommand,
+	"cover":     coverCommand,
+	"sprites":   spritesCommand,
+}
+
+func readROM(path string) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	binary, err := io.ReadAll(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return binary
+}
+
+func lookupQuirks(name string) e.Quirks {
+	q, err := e.LookupQuirks(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return q
+}
+
+// loadSymbols loads path, or the symbol file next to the rom if path is empty.
+func loadSymbols(path, rom string) *e.Symbols {
+	if path == "" {
+		path = strings.TrimSuffix(rom, filepath.Ext(rom)) + ".sym"
+		if _, err := os.Stat(path); err != nil {
+			return nil
+		}
+	}
+	s, err := e.LoadSymbols(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return s
+}
+
+// parseRange parses an inclusive hex address range such as "200-2ff".
+func parseRange(s string) (uint16, uint16) {
+	var from, to uint16
+	if _, err := fmt.Sscanf(s, "%x-%x", &from, &to); err != nil {
+		log.Fatalf("invalid address range %q: %v", s, err)
+	}
+	return from, to
+}
+
+// parseAddresses parses a comma separated list of hex addresses or labels.
+func parseAddresses(s string, symbols *e.Symbols) []uint16 {
+	addrs := []uint16{}
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if a, ok := symbols.Address(f); ok {
+			addrs = append(addrs, a)
+			continue
+		}
+		a, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(f), "0x"), 16, 16)
+		if err != nil {
+			log.Fatalf("invalid address %q", f)
+		}
+		addrs = append(addrs, uint16(a))
+	}
+	return addrs
+}
+
+// chip8 diff [-a preset] [-b preset] [-frames n] rom
+func diffCommand(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	a := fs.String("a", "default", "quirks preset of the first machine")
+	b := fs.String("b", "vip", "quirks preset of the second machine")
+	frames := fs.Int("frames", 600, "number of frames to run")
+	fs.Parse(args)
+
+	d := e.Lockstep(readROM(fs.Arg(0)), lookupQuirks(*a), lookupQuirks(*b), *frames, nil)
+	if d == nil {
+		fmt.Printf("no divergence in %d frames\n", *frames)
+		return
+	}
+	fmt.Print(d)
+	os.Exit(1)
+}
+
+// chip8 tracediff [-context n] a.trace b.trace
+func tracediffCommand(args []string) {
+	fs := flag.NewFlagSet("tracediff", flag.ExitOnError)
+	context := fs.Int("context", 5, "number of instructions shown around the divergence")
+	strict := fs.Bool("strict", false, "also count a register logged by only one trace as a divergence")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		log.Fatal("usage: chip8 tracediff [-context n] [-strict] a.trace b.trace")
+	}
+
+	a, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer a.Close()
+	b, err := os.Open(fs.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer b.Close()
+
+	diverged, err := e.TraceDiff(os.Stdout, a, b, fs.Arg(0), fs.Arg(1), *context, *strict)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if diverged {
+		os.Exit(1)
+	}
+}
+
+// chip8 profile [-frames n] [-quirks preset] [-top n] [-o profile.pb.gz] rom
+func profileCommand(args []string) {
+	fs := flag.NewFlagSet("profile", flag.ExitOnError)
+	frames := fs.Int("frames", 600, "number of frames to run")
+	quirks := fs.String("quirks", "default", "quirks preset")
+	top := fs.Int("top", 20, "number of addresses in the report")
+	out := fs.String("o", "", "write a pprof profile to this file")
+	fs.Parse(args)
+
+	p := e.NewProfiler()
+	c := e.NewChip8(readROM(fs.Arg(0)), lookupQuirks(*quirks))
+	c.SetProfiler(p)
+	e.RunFrames(c, *frames, nil)
+	p.WriteReport(os.Stdout, *top)
+	if *out != "" {
+		writeProfile(p, *out, fs.Arg(0))
+	}
+}
+
+func writeProfile(p *e.Profiler, path, rom string) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if err := p.WriteProfile(f, rom); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func writeHeatmap(h *e.Heatmap, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if err := h.WritePNG(f, 8); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// chip8 cover [-html report.html] [-quirks preset] rom a.cov [b.cov ...]
+func coverCommand(args []string) {
+	fs := flag.NewFlagSet("cover", flag.ExitOnError)
+	html := fs.String("html", "", "write an HTML report to this file")
+	quirks := fs.String("quirks", "default", "quirks preset the rom runs with, for the operands shown")
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		log.Fatal("usage: chip8 cover [-html report.html] [-quirks preset] rom a.cov [b.cov ...]")
+	}
+
+	rom := readROM(fs.Arg(0))
+	cv := &e.Coverage{}
+	for _, path := range fs.Args()[1:] {
+		c, err := e.ReadCoverage(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cv.Merge(c)
+	}
+
+	if *html == "" {
+		e.WriteCoverageText(os.Stdout, rom, cv, lookupQuirks(*quirks))
+		return
+	}
+	f, err := os.Create(*html)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if err := e.WriteCoverageHTML(f, fs.Arg(0), rom, cv, lookupQuirks(*quirks)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// chip8 sprites [-o sprites.png] [-scale n] [-scan=false] [-at addr[:n],...] rom
+func spritesCommand(args []string) {
+	fs := flag.NewFlagSet("sprites", flag.ExitOnError)
+	out := fs.String("o", "sprites.png", "write the sprite sheet to this file")
+	scale := fs.Int("scale", 4, "size of a sprite pixel in the image")
+	at := fs.String("at", "", "comma separated addr[:n] to show instead of the sprites found in the rom (n 0 is 16x16)")
+	scan := fs.Bool("scan", true, "also show data that looks like sprites but is not drawn by an ANNN, DXYN pair")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("usage: chip8 sprites [-o sprites.png] [-scale n] [-scan=false] [-at addr[:n],...] rom")
+	}
+
+	rom := readROM(fs.Arg(0))
+	refs := e.FindSprites(rom)
+	guessed := 0
+	if *scan {
+		more := e.ScanSprites(rom, refs)
+		refs, guessed = append(refs, more...), len(more)
+	}
+	if *at != "" {
+		refs, guessed = parseSpriteRefs(*at), 0
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if err := e.WriteSpriteSheet(f, rom, refs, *scale); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d sprites (%d guessed) written to %s\n", len(refs), guessed, *out)
+}
+
+// parseSpriteRefs parses "2A4:5,300:0,310", a missing height is 15.
+func parseSpriteRefs(s string) []e.SpriteRef {
+	refs := []e.SpriteRef{}
+	for _, f := range strings.Split(s, ",") {
+		r := e.SpriteRef{Height: 15}
+		var err error
+		if strings.Contains(f, ":") {
+			_, err = fmt.Sscanf(f, "%x:%d", &r.Addr, &r.Height)
+		} else {
+			_, err = fmt.Sscanf(f, "%x", &r.Addr)
+		}
+		if err != nil || r.Height < 0 || r.Height > 15 {
+			log.Fatalf("invalid sprite %q", f)
+		}
+		refs = append(refs, r)
+	}
+	return refs
+}
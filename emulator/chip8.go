@@ -1,5 +1,11 @@
 package emulator
 
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
 const (
 	Chip8DisplayW          = 64
 	Chip8DisplayH          = 32
@@ -7,7 +13,9 @@ const (
 	CharacterSpriteBytes   = 5
 	ProgramOffset          = 0x200
 	Chip8Frequency         = 60 * 8
+	CyclesPerFrame         = Chip8Frequency / 60
 	OpHistoryNum           = 16
+	AddressMask            = 0xfff
 )
 
 type Chip8 struct {
@@ -21,9 +29,27 @@ type Chip8 struct {
 	stack [16]uint16     // stack
 	keys  [16]uint8      // keyboards state
 	disp  [64 * 32]uint8 // graphics
+	depth uint8          // number of return addresses on the stack
+	fault string         // stack fault raised by the last instruction
 
-	ophistory      [OpHistoryNum]string
+	quirks   Quirks
+	rand     *rand.Rand
+	cycles   uint64
+	spriteN  uint8 // N of the last DXYN
+	tracer   *Tracer
+	profiler *Profiler
+	coverage *Coverage
+	heatmap  *Heatmap
+
+	ophistory      [OpHistoryNum]opHistoryEntry
 	ophistoryIndex int
+	written        [4096]uint64 // cycle (1-based) of the last store to each address
+}
+
+type opHistoryEntry struct {
+	pc    uint16
+	op    uint16
+	valid bool
 }
 
 var characterSprites = []uint8{
@@ -45,19 +71,107 @@ var characterSprites = []uint8{
 	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
 }
 
-func newChip8(b []byte) *Chip8 {
+// NewChip8 creates a machine with the rom loaded at ProgramOffset. It runs
+// without any frontend, see RunFrames.
+func NewChip8(b []byte, q Quirks) *Chip8 {
 	c := &Chip8{}
 	c.pc = ProgramOffset
 	c.sp = 0x0f
+	c.quirks = q
+	c.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
 
 	copy(c.mem[ProgramOffset:], []uint8(b))
 	copy(c.mem[CharacterSpritesOffset:], characterSprites)
 	return c
 }
 
+// registers is the subset of the machine state reported in traces.
+type registers struct {
+	v  [16]uint8
+	i  uint16
+	dt uint8
+	st uint8
+	sp uint8
+}
+
+func (c *Chip8) registers() registers {
+	return registers{v: c.v, i: c.i, dt: c.dt, st: c.st, sp: c.sp}
+}
+
+func (c *Chip8) SetTracer(t *Tracer) {
+	c.tracer = t
+}
+
+func (c *Chip8) SetProfiler(p *Profiler) {
+	c.profiler = p
+}
+
+func (c *Chip8) SetCoverage(cv *Coverage) {
+	c.coverage = cv
+}
+
+func (c *Chip8) SetHeatmap(h *Heatmap) {
+	c.heatmap = h
+}
+
 func (c *Chip8) step() {
+	c.fault = ""
+	pc := c.pc
+	var before registers
+	if c.tracer != nil {
+		before = c.registers()
+	}
+	var chain callChain
+	if c.profiler != nil {
+		chain = c.callChain()
+	}
+
 	op := c.fetchOpcode()
-	c.execOpcode(op)
+	mnemonic := c.execOpcode(op)
+	c.pc &= AddressMask
+	c.cycles++
+
+	if c.tracer != nil {
+		c.tracer.trace(c.cycles, pc, op, mnemonic, before, c.registers())
+	}
+	if c.profiler != nil {
+		c.profiler.record(chain, op, mnemonic)
+	}
+}
+
+// RunFrame runs the instructions and timers of one 60Hz frame.
+func (c *Chip8) RunFrame() {
+	for i := 0; i < CyclesPerFrame; i++ {
+		c.step()
+	}
+	c.endFrame()
+}
+
+// Display is the framebuffer, Chip8DisplayW x Chip8DisplayH pixels row by
+// row, 1 lit and 0 dark. It is updated in place.
+func (c *Chip8) Display() []uint8 {
+	return c.disp[:]
+}
+
+func (c *Chip8) SetKey(key uint8, down bool) {
+	var v uint8
+	if down {
+		v = 1
+	}
+	c.keys[key&0xf] = v
+}
+
+// Sound reports whether the buzzer sounds, i.e. the sound timer runs.
+func (c *Chip8) Sound() bool {
+	return c.st > 0
+}
+
+// endFrame runs the 60Hz work: timers and heatmap decay.
+func (c *Chip8) endFrame() {
+	c.decrementTimer()
+	if c.heatmap != nil {
+		c.heatmap.decay()
+	}
 }
 
 func (c *Chip8) decrementTimer() {
@@ -70,11 +184,45 @@ func (c *Chip8) decrementTimer() {
 }
 
 func (c *Chip8) fetchOpcode() uint16 {
-	op := uint16(c.mem[c.pc])<<8 | uint16(c.mem[c.pc+1])
+	if c.coverage != nil {
+		c.coverage.mark(c.pc)
+	}
+	if c.heatmap != nil {
+		c.heatmap.exec(c.pc)
+		c.heatmap.exec(c.pc + 1)
+	}
+	op := c.opcodeAt(c.pc)
 	c.pc += 2
 	return op
 }
 
+func (c *Chip8) opcodeAt(addr uint16) uint16 {
+	return uint16(c.mem[addr&AddressMask])<<8 | uint16(c.mem[(addr+1)&AddressMask])
+}
+
+func (c *Chip8) store(addr uint16, v uint8) {
+	addr &= AddressMask
+	c.mem[addr] = v
+	c.written[addr] = c.cycles + 1
+	if c.heatmap != nil {
+		c.heatmap.write(addr)
+	}
+}
+
+func (c *Chip8) load(addr uint16) uint8 {
+	if c.heatmap != nil {
+		c.heatmap.read(addr)
+	}
+	return c.mem[addr&AddressMask]
+}
+
+// recentlyWritten reports whether addr was stored to during the last frame
+// worth of cycles.
+func (c *Chip8) recentlyWritten(addr uint16) bool {
+	w := c.written[addr&AddressMask]
+	return w != 0 && c.cycles-w < CyclesPerFrame
+}
+
 func (c *Chip8) updateCarryFlag(b bool) {
 	if b {
 		c.v[0xf] = 1
@@ -84,28 +232,50 @@ func (c *Chip8) updateCarryFlag(b bool) {
 }
 
 func (c *Chip8) pushStack(v uint16) {
+	if int(c.depth) == len(c.stack) {
+		c.fault = fmt.Sprintf("CALL at %03X with full stack", (v-2)&AddressMask)
+	} else {
+		c.depth++
+	}
 	c.stack[c.sp] = v
-	c.sp--
+	c.sp = (c.sp - 1) & 0x0f
 }
 
 func (c *Chip8) popStack() uint16 {
-	c.sp++
+	if c.depth == 0 {
+		c.fault = fmt.Sprintf("RET at %03X with empty stack", (c.pc-2)&AddressMask)
+	} else {
+		c.depth--
+	}
+	c.sp = (c.sp + 1) & 0x0f
 	return c.stack[c.sp]
 }
 
+// callStack returns the return addresses on the stack, innermost first.
+func (c *Chip8) callStack() []uint16 {
+	r := []uint16{}
+	for i := uint8(1); i <= c.depth; i++ {
+		r = append(r, c.stack[(c.sp+i)&0x0f])
+	}
+	return r
+}
+
 func (c *Chip8) draw(x, y, n uint8) bool {
 	flipped := false
-	sm := c.mem[c.i:]
 	for iy := uint8(0); iy < n; iy++ {
+		sm := c.load(c.i + uint16(iy))
 		for ix := uint8(0); ix < 8; ix++ {
 			tx := int(x) + int(ix)
 			ty := int(y) + int(iy)
-			if tx >= Chip8DisplayW || ty >= Chip8DisplayH {
+			if c.quirks.Wrap {
+				tx %= Chip8DisplayW
+				ty %= Chip8DisplayH
+			} else if tx >= Chip8DisplayW || ty >= Chip8DisplayH {
 				continue
 			}
 
 			s := c.disp[ty*Chip8DisplayW+tx]
-			d := (sm[iy] >> (7 - ix)) & 0x01
+			d := (sm >> (7 - ix)) & 0x01
 			c.disp[ty*Chip8DisplayW+tx] ^= d
 			if s == 1 && d == 1 {
 				flipped = true
@@ -0,0 +1,157 @@
+package emulator
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+)
+
+const coverageMagic = "C8CV"
+
+// Coverage is a bitmap of the addresses an instruction was fetched from.
+type Coverage struct {
+	bits [4096 / 8]uint8
+}
+
+func (cv *Coverage) mark(addr uint16) {
+	addr &= AddressMask
+	cv.bits[addr/8] |= 1 << (addr % 8)
+}
+
+func (cv *Coverage) Covered(addr uint16) bool {
+	addr &= AddressMask
+	return cv.bits[addr/8]&(1<<(addr%8)) != 0
+}
+
+func (cv *Coverage) Merge(o *Coverage) {
+	for i := range cv.bits {
+		cv.bits[i] |= o.bits[i]
+	}
+}
+
+func ReadCoverage(path string) (*Coverage, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cv := &Coverage{}
+	if len(b) != len(coverageMagic)+len(cv.bits) || string(b[:len(coverageMagic)]) != coverageMagic {
+		return nil, fmt.Errorf("%s: not a coverage file", path)
+	}
+	copy(cv.bits[:], b[len(coverageMagic):])
+	return cv, nil
+}
+
+// MergeCoverageFile adds the coverage already stored in path (if any) to cv
+// and writes the result back, so coverage aggregates across runs.
+func MergeCoverageFile(path string, cv *Coverage) error {
+	old, err := ReadCoverage(path)
+	if err == nil {
+		cv.Merge(old)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(coverageMagic), cv.bits[:]...), 0644)
+}
+
+const (
+	coverageExecuted = "executed"
+	coverageMissed   = "missed"
+	coverageData     = "data"
+)
+
+type coverageLine struct {
+	Addr   uint16
+	Bytes  []byte
+	Text   string
+	Status string
+}
+
+func coverageLines(rom []byte, cv *Coverage, q Quirks) []coverageLine {
+	c := NewChip8(rom, q)
+	code := reachable(c.mem[:])
+	end := ProgramOffset + len(rom)
+	if end > len(c.mem) {
+		end = len(c.mem)
+	}
+
+	lines := []coverageLine{}
+	for addr := ProgramOffset; addr < end; {
+		a := uint16(addr)
+		if (cv.Covered(a) || code[a]) && addr+1 < end {
+			op := c.opcodeAt(a)
+			status := coverageMissed
+			if cv.Covered(a) {
+				status = coverageExecuted
+			}
+			lines = append(lines, coverageLine{a, c.mem[addr : addr+2], disassemble(op, q), status})
+			addr += 2
+			continue
+		}
+		lines = append(lines, coverageLine{a, c.mem[addr : addr+1], fmt.Sprintf("DB   #%02X", c.mem[addr]), coverageData})
+		addr++
+	}
+	return lines
+}
+
+func coverageSummary(lines []coverageLine) string {
+	executed, code := 0, 0
+	for _, l := range lines {
+		if l.Status != coverageData {
+			code++
+		}
+		if l.Status == coverageExecuted {
+			executed++
+		}
+	}
+	percent := 0.0
+	if code > 0 {
+		percent = 100 * float64(executed) / float64(code)
+	}
+	return fmt.Sprintf("%d of %d instructions executed (%.1f%%)", executed, code, percent)
+}
+
+// WriteCoverageText writes the disassembly of rom with each line marked
+// '+' (executed), '-' (code never executed) or ' ' (data). q chooses the
+// operands shown, as for BNNN.
+func WriteCoverageText(w io.Writer, rom []byte, cv *Coverage, q Quirks) {
+	lines := coverageLines(rom, cv, q)
+	fmt.Fprintf(w, "; %s\n", coverageSummary(lines))
+	marks := map[string]string{coverageExecuted: "+", coverageMissed: "-", coverageData: " "}
+	for _, l := range lines {
+		fmt.Fprintf(w, "%s %03X  %-4X  %s\n", marks[l.Status], l.Addr, l.Bytes, l.Text)
+	}
+}
+
+var coverageHTML = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { background: #202020; color: #c0c0c0; font-family: monospace; }
+.executed { color: #40ff40; }
+.missed { color: #ff4040; }
+.data { color: #808080; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Summary}}</p>
+<pre>
+{{range .Lines}}<span class="{{.Status}}">{{printf "%03X  %-4X  %s" .Addr .Bytes .Text}}</span>
+{{end}}</pre>
+</body>
+</html>
+`))
+
+func WriteCoverageHTML(w io.Writer, title string, rom []byte, cv *Coverage, q Quirks) error {
+	lines := coverageLines(rom, cv, q)
+	return coverageHTML.Execute(w, struct {
+		Title   string
+		Summary string
+		Lines   []coverageLine
+	}{title, coverageSummary(lines), lines})
+}
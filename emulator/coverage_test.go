@@ -0,0 +1,58 @@
+package emulator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// skips over a never executed instruction and ends in data
+var coverageROM = assemble(0x6001, 0x3001, 0x6002, 0x1206, 0xF0F0)
+
+func TestCoverageText(t *testing.T) {
+	c := NewChip8(coverageROM, QuirkPresets["default"])
+	c.SetCoverage(&Coverage{})
+	RunFrames(c, 1, nil)
+
+	var b strings.Builder
+	WriteCoverageText(&b, coverageROM, c.coverage, Quirks{})
+	assert.Equal(t, `; 3 of 4 instructions executed (75.0%)
++ 200  6001  LD   V0,#01
++ 202  3001  SE   V0,#01
+- 204  6002  LD   V0,#02
++ 206  1206  GOTO 206
+  208  F0    DB   #F0
+  209  F0    DB   #F0
+`, b.String())
+}
+
+func TestCoverageMergeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rom.cov")
+
+	a := &Coverage{}
+	a.mark(0x200)
+	assert.NoError(t, MergeCoverageFile(path, a))
+
+	b := &Coverage{}
+	b.mark(0x204)
+	assert.NoError(t, MergeCoverageFile(path, b))
+
+	cv, err := ReadCoverage(path)
+	assert.NoError(t, err)
+	assert.True(t, cv.Covered(0x200))
+	assert.False(t, cv.Covered(0x202))
+	assert.True(t, cv.Covered(0x204))
+}
+
+func TestCoverageHTML(t *testing.T) {
+	cv := &Coverage{}
+	cv.mark(0x200)
+
+	var b strings.Builder
+	assert.NoError(t, WriteCoverageHTML(&b, "rom <1>", coverageROM, cv, Quirks{}))
+	assert.Contains(t, b.String(), "<title>rom &lt;1&gt;</title>")
+	assert.Contains(t, b.String(), `<span class="executed">200  6001  LD   V0,#01</span>`)
+	assert.Contains(t, b.String(), `<span class="missed">202  3001  SE   V0,#01</span>`)
+}
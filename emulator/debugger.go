@@ -0,0 +1,477 @@
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Debugger runs console commands against a machine and decides when a
+// running machine has to stop: breakpoints, watches and pending step or
+// until commands. Numbers are hex, like everywhere in the debug views.
+type Debugger struct {
+	out         io.Writer
+	symbols     *Symbols
+	breakpoints map[uint16]bool
+	watches     []watch
+	history     []string
+
+	steps int               // instructions left to run, 0 if none pending
+	until func(*Chip8) bool // stop condition of a pending until
+}
+
+type watch struct {
+	expr  string
+	value int
+}
+
+func NewDebugger(out io.Writer, s *Symbols) *Debugger {
+	return &Debugger{out: out, symbols: s, breakpoints: map[uint16]bool{}}
+}
+
+var debuggerCommands = []struct {
+	name, args, help string
+}{
+	{"break", "[addr]", "set a breakpoint, list them without addr"},
+	{"delete", "addr", "remove a breakpoint"},
+	{"watch", "expr", "stop when the value of expr changes, e.g. watch [i]"},
+	{"unwatch", "expr", "remove a watch"},
+	{"regs", "", "show the registers"},
+	{"set", "reg=value", "set v0-vf, i, pc, dt, st or [addr]"},
+	{"mem", "addr [len]", "dump memory"},
+	{"step", "[n]", "run n instructions, n in decimal"},
+	{"next", "", "step over: run a CALL until it returns"},
+	{"finish", "", "step out: run until the current subroutine returns"},
+	{"until", "ret|addr", "run until the current subroutine returns or pc is addr"},
+	{"frames", "[n]", "run n frames, n in decimal"},
+	{"continue", "", "leave step mode"},
+	{"save", "file", "save the machine state"},
+	{"load", "file", "load a machine state"},
+	{"history", "", "list the commands entered so far"},
+	{"help", "", "list the commands"},
+}
+
+// Exec runs one command line. An empty line repeats the last command. It
+// reports whether the machine should start running.
+func (d *Debugger) Exec(c *Chip8, line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		if len(d.history) == 0 {
+			return false
+		}
+		line = d.history[len(d.history)-1]
+	} else {
+		d.history = append(d.history, line)
+	}
+
+	resume, err := d.exec(c, line)
+	if err != nil {
+		fmt.Fprintln(d.out, err)
+		return false
+	}
+	return resume
+}
+
+// ExecScript runs every line of path, skipping comments starting with '#'.
+// A line that runs the machine, like step or until, is finished with run
+// before the next line. It reports whether a line asked to leave step mode.
+func (d *Debugger) ExecScript(c *Chip8, path string, run func() error) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	resume := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fmt.Fprintf(d.out, "> %s\n", line)
+		if !d.Exec(c, line) {
+			continue
+		}
+		if !d.pending() {
+			resume = true
+			continue
+		}
+		if err := run(); err != nil {
+			return resume, fmt.Errorf("%s: %v", line, err)
+		}
+	}
+	return resume, scanner.Err()
+}
+
+func (d *Debugger) exec(c *Chip8, line string) (bool, error) {
+	fields := strings.Fields(line)
+	cmd, args := fields[0], fields[1:]
+	switch cmd {
+	case "break", "b":
+		if len(args) == 0 {
+			d.listBreakpoints()
+			return false, nil
+		}
+		addr, err := d.address(c, args[0])
+		if err != nil {
+			return false, err
+		}
+		d.breakpoints[addr] = true
+
+	case "delete", "d":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: delete addr")
+		}
+		addr, err := d.address(c, args[0])
+		if err != nil {
+			return false, err
+		}
+		delete(d.breakpoints, addr)
+
+	case "watch", "w":
+		if len(args) == 0 {
+			for _, w := range d.watches {
+				fmt.Fprintf(d.out, "%s = %X\n", w.expr, w.value)
+			}
+			return false, nil
+		}
+		expr := strings.Join(args, "")
+		v, err := d.eval(c, expr)
+		if err != nil {
+			return false, err
+		}
+		d.watches = append(d.watches, watch{expr, v})
+
+	case "unwatch":
+		expr := strings.Join(args, "")
+		for i, w := range d.watches {
+			if w.expr == expr {
+				d.watches = append(d.watches[:i], d.watches[i+1:]...)
+				return false, nil
+			}
+		}
+		return false, fmt.Errorf("no watch %s", expr)
+
+	case "regs", "r":
+		d.printRegisters(c)
+
+	case "set":
+		return false, d.set(c, strings.Join(args, ""))
+
+	case "mem", "m":
+		if len(args) == 0 || len(args) > 2 {
+			return false, fmt.Errorf("usage: mem addr [len]")
+		}
+		addr, err := d.eval(c, args[0])
+		if err != nil {
+			return false, err
+		}
+		n := 0x40
+		if len(args) == 2 {
+			if n, err = parseHex(args[1]); err != nil {
+				return false, err
+			}
+		}
+		d.dump(c, uint16(addr), n)
+
+	case "step", "s":
+		n := 1
+		if len(args) == 1 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n <= 0 {
+				return false, fmt.Errorf("invalid count %s", args[0])
+			}
+		}
+		d.Step(n)
+		return true, nil
+
+	case "next", "n":
+		d.StepOver(c)
+		return true, nil
+
+	case "finish", "f":
+		return true, d.StepOut(c)
+
+	case "until", "u":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: until ret|addr")
+		}
+		if args[0] == "ret" {
+			return true, d.StepOut(c)
+		}
+		addr, err := d.address(c, args[0])
+		if err != nil {
+			return false, err
+		}
+		d.RunTo(addr)
+		return true, nil
+
+	case "frames":
+		n := 1
+		if len(args) == 1 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n <= 0 {
+				return false, fmt.Errorf("invalid count %s", args[0])
+			}
+		}
+		d.RunFrames(c, n)
+		return true, nil
+
+	case "continue", "c":
+		d.cancel()
+		return true, nil
+
+	case "save":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: save file")
+		}
+		return false, c.SaveState(args[0])
+
+	case "load":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: load file")
+		}
+		if err := c.LoadState(args[0]); err != nil {
+			return false, err
+		}
+		d.printLocation(c)
+
+	case "history":
+		for i, l := range d.history {
+			fmt.Fprintf(d.out, "%3d %s\n", i+1, l)
+		}
+
+	case "help", "h", "?":
+		for _, cmd := range debuggerCommands {
+			fmt.Fprintf(d.out, "%-8s %-10s %s\n", cmd.name, cmd.args, cmd.help)
+		}
+
+	default:
+		return false, fmt.Errorf("unknown command %q, try help", cmd)
+	}
+	return false, nil
+}
+
+// The run commands below only arm a stop condition: the caller runs the
+// machine, calling check after every instruction, until check says stop.
+
+// Step stops after n instructions.
+func (d *Debugger) Step(n int) {
+	d.cancel()
+	d.steps = n
+}
+
+// StepOver runs a CALL at pc until it returns, any other instruction is a
+// single step.
+func (d *Debugger) StepOver(c *Chip8) {
+	d.cancel()
+	if c.opcodeAt(c.pc)&0xF000 != 0x2000 {
+		d.steps = 1
+		return
+	}
+	depth := c.depth
+	d.until = func(c *Chip8) bool { return c.depth <= depth }
+}
+
+// StepOut runs until the current subroutine returns.
+func (d *Debugger) StepOut(c *Chip8) error {
+	d.cancel()
+	depth := c.depth
+	if depth == 0 {
+		return fmt.Errorf("not in a subroutine")
+	}
+	d.until = func(c *Chip8) bool { return c.depth < depth }
+	return nil
+}
+
+// RunTo stops when pc reaches addr.
+func (d *Debugger) RunTo(addr uint16) {
+	d.cancel()
+	addr &= AddressMask
+	d.until = func(c *Chip8) bool { return c.pc == addr }
+}
+
+// RunFrames stops after n frames worth of instructions.
+func (d *Debugger) RunFrames(c *Chip8, n int) {
+	d.cancel()
+	end := c.cycles + uint64(n*CyclesPerFrame)
+	d.until = func(c *Chip8) bool { return c.cycles >= end }
+}
+
+// check is called after every executed instruction. It reports whether the
+// machine has to stop and why; a finished step or until has no reason.
+func (d *Debugger) check(c *Chip8) (string, bool) {
+	reason, stop := "", false
+	for i, w := range d.watches {
+		v, err := d.eval(c, w.expr)
+		if err == nil && v != w.value {
+			reason, stop = fmt.Sprintf("watch %s: %X -> %X", w.expr, w.value, v), true
+			d.watches[i].value = v
+		}
+	}
+	if d.breakpoints[c.pc] && !stop {
+		reason, stop = fmt.Sprintf("breakpoint at %03X", c.pc), true
+	}
+	if d.steps > 0 {
+		d.steps--
+		stop = stop || d.steps == 0
+	}
+	if d.until != nil && d.until(c) {
+		stop = true
+	}
+	if stop {
+		d.cancel()
+	}
+	return reason, stop
+}
+
+// stop reports why the machine stopped and where.
+func (d *Debugger) stop(c *Chip8, reason string) {
+	if reason != "" {
+		fmt.Fprintln(d.out, reason)
+	}
+	d.printLocation(c)
+}
+
+// pending reports whether a step or until command is running.
+func (d *Debugger) pending() bool {
+	return d.steps > 0 || d.until != nil
+}
+
+func (d *Debugger) cancel() {
+	d.steps, d.until = 0, nil
+}
+
+func (d *Debugger) toggleBreakpoint(addr uint16) {
+	if d.breakpoints[addr] {
+		delete(d.breakpoints, addr)
+	} else {
+		d.breakpoints[addr] = true
+	}
+}
+
+func (d *Debugger) listBreakpoints() {
+	addrs := []int{}
+	for a := range d.breakpoints {
+		addrs = append(addrs, int(a))
+	}
+	sort.Ints(addrs)
+	for _, a := range addrs {
+		fmt.Fprintf(d.out, "%03X %s\n", a, d.symbols.Lookup(uint16(a)))
+	}
+}
+
+func (d *Debugger) printLocation(c *Chip8) {
+	fmt.Fprintln(d.out, formatInstruction(c.pc, c.opcodeAt(c.pc), d.symbols, c.quirks))
+}
+
+func (d *Debugger) printRegisters(c *Chip8) {
+	fmt.Fprintf(d.out, "PC=%03X I=%03X SP=%X DT=%02X ST=%02X\n", c.pc, c.i, c.sp, c.dt, c.st)
+	for i, v := range c.v {
+		sep := " "
+		if i%8 == 7 {
+			sep = "\n"
+		}
+		fmt.Fprintf(d.out, "V%X=%02X%s", i, v, sep)
+	}
+}
+
+func (d *Debugger) dump(c *Chip8, addr uint16, n int) {
+	for row := 0; row < n; row += 16 {
+		fmt.Fprintf(d.out, "%03X:", (addr+uint16(row))&AddressMask)
+		for j := row; j < row+16 && j < n; j++ {
+			fmt.Fprintf(d.out, " %02X", c.mem[(addr+uint16(j))&AddressMask])
+		}
+		fmt.Fprintln(d.out)
+	}
+}
+
+// set assigns "reg=value" where reg is a register or [addr].
+func (d *Debugger) set(c *Chip8, s string) error {
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return fmt.Errorf("usage: set reg=value")
+	}
+	name, value := strings.ToLower(s[:i]), s[i+1:]
+	v, err := d.eval(c, value)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]"):
+		addr, err := d.eval(c, name[1:len(name)-1])
+		if err != nil {
+			return err
+		}
+		c.mem[addr&AddressMask] = uint8(v)
+	case len(name) == 2 && name[0] == 'v':
+		r, err := strconv.ParseUint(name[1:], 16, 4)
+		if err != nil {
+			return fmt.Errorf("unknown register %s", name)
+		}
+		c.v[r] = uint8(v)
+	case name == "i":
+		c.i = uint16(v) & AddressMask
+	case name == "pc":
+		c.pc = uint16(v) & AddressMask
+	case name == "dt":
+		c.dt = uint8(v)
+	case name == "st":
+		c.st = uint8(v)
+	default:
+		return fmt.Errorf("unknown register %s", name)
+	}
+	return nil
+}
+
+// eval returns the value of a register, [addr] (a memory byte), a label or
+// a hex number.
+func (d *Debugger) eval(c *Chip8, expr string) (int, error) {
+	e := strings.ToLower(expr)
+	switch {
+	case strings.HasPrefix(e, "[") && strings.HasSuffix(e, "]"):
+		addr, err := d.eval(c, e[1:len(e)-1])
+		if err != nil {
+			return 0, err
+		}
+		return int(c.mem[addr&AddressMask]), nil
+	case len(e) == 2 && e[0] == 'v' && strings.ContainsRune("0123456789abcdef", rune(e[1])):
+		r, _ := strconv.ParseUint(e[1:], 16, 4)
+		return int(c.v[r]), nil
+	case e == "i":
+		return int(c.i), nil
+	case e == "pc":
+		return int(c.pc), nil
+	case e == "sp":
+		return int(c.sp), nil
+	case e == "dt":
+		return int(c.dt), nil
+	case e == "st":
+		return int(c.st), nil
+	}
+	if addr, ok := d.symbols.Address(expr); ok {
+		return int(addr), nil
+	}
+	return parseHex(expr)
+}
+
+// address evaluates expr as a label, register or hex address.
+func (d *Debugger) address(c *Chip8, expr string) (uint16, error) {
+	v, err := d.eval(c, expr)
+	return uint16(v) & AddressMask, err
+}
+
+func parseHex(s string) (int, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(s), "0x"), "#")
+	v, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %s", s)
+	}
+	return int(v), nil
+}
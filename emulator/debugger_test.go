@@ -0,0 +1,181 @@
+package emulator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// run steps c like the emulator loop until the debugger stops it.
+func run(d *Debugger, c *Chip8, max int) bool {
+	for i := 0; i < max; i++ {
+		c.step()
+		if reason, stop := d.check(c); stop {
+			d.stop(c, reason)
+			return true
+		}
+	}
+	return false
+}
+
+// main: V0=0, loop: CALL sub, JP loop; sub: ADD V0,1, LD I,300, LD [I],V0, RET
+var debuggerROM = assemble(0x6000, 0x2206, 0x1202, 0x7001, 0xA300, 0xF055, 0x00EE)
+
+func TestDebuggerBreakAndStep(t *testing.T) {
+	var out bytes.Buffer
+	d := NewDebugger(&out, nil)
+	c := NewChip8(debuggerROM, QuirkPresets["default"])
+
+	assert.False(t, d.Exec(c, "break 206"))
+	assert.True(t, d.Exec(c, "continue"))
+	assert.True(t, run(d, c, 100))
+	assert.Equal(t, uint16(0x206), c.pc)
+	assert.Equal(t, "breakpoint at 206\n206-7001 ADD  V0,#01\n", out.String())
+
+	out.Reset()
+	assert.True(t, d.Exec(c, "step 3"))
+	assert.True(t, run(d, c, 100))
+	assert.Equal(t, uint16(0x20C), c.pc)
+	assert.Equal(t, "20C-00EE RET  \n", out.String())
+
+	// an empty line repeats the last command
+	out.Reset()
+	assert.True(t, d.Exec(c, ""))
+	assert.True(t, run(d, c, 100))
+	assert.Equal(t, "breakpoint at 206\n206-7001 ADD  V0,#01\n", out.String())
+}
+
+func TestDebuggerUntilAndWatch(t *testing.T) {
+	var out bytes.Buffer
+	d := NewDebugger(&out, nil)
+	c := NewChip8(debuggerROM, QuirkPresets["default"])
+	d.Exec(c, "until 206")
+	run(d, c, 100)
+	assert.Equal(t, uint16(0x206), c.pc)
+
+	assert.True(t, d.Exec(c, "until ret"))
+	assert.True(t, run(d, c, 100))
+	assert.Equal(t, uint16(0x204), c.pc)
+
+	d.Exec(c, "watch [300]")
+	out.Reset()
+	d.Exec(c, "continue")
+	assert.True(t, run(d, c, 100))
+	assert.Equal(t, "watch [300]: 1 -> 2\n20C-00EE RET  \n", out.String())
+
+	d.Exec(c, "until ret")
+	run(d, c, 100)
+	out.Reset()
+	d.Exec(c, "until ret")
+	assert.Equal(t, "not in a subroutine\n", out.String())
+}
+
+func TestDebuggerInspect(t *testing.T) {
+	var out bytes.Buffer
+	s, _ := ParseSymbols(strings.NewReader("sub = 0x206\n"))
+	d := NewDebugger(&out, s)
+	c := NewChip8(debuggerROM, QuirkPresets["default"])
+
+	d.Exec(c, "set v3=10")
+	d.Exec(c, "set i = sub")
+	d.Exec(c, "set [i]=ff")
+	d.Exec(c, "regs")
+	assert.Equal(t, "PC=200 I=206 SP=F DT=00 ST=00\n"+
+		"V0=00 V1=00 V2=00 V3=10 V4=00 V5=00 V6=00 V7=00\n"+
+		"V8=00 V9=00 VA=00 VB=00 VC=00 VD=00 VE=00 VF=00\n", out.String())
+
+	out.Reset()
+	d.Exec(c, "mem sub 4")
+	d.Exec(c, "break sub+2")
+	d.Exec(c, "break")
+	d.Exec(c, "set q=1")
+	d.Exec(c, "frobnicate")
+	assert.Equal(t, "206: FF 01 A3 00\n208 sub+2\nunknown register q\nunknown command \"frobnicate\", try help\n", out.String())
+
+	out.Reset()
+	d.Exec(c, "history")
+	assert.True(t, strings.HasPrefix(out.String(), "  1 set v3=10\n"))
+}
+
+func TestDebuggerSaveLoad(t *testing.T) {
+	var out bytes.Buffer
+	d := NewDebugger(&out, nil)
+	c := NewChip8(debuggerROM, QuirkPresets["default"])
+	path := filepath.Join(t.TempDir(), "state1")
+
+	for i := 0; i < 5; i++ {
+		c.step()
+	}
+	d.Exec(c, "save "+path)
+	saved := *c
+	for i := 0; i < 10; i++ {
+		c.step()
+	}
+	d.Exec(c, "load "+path)
+	assert.Equal(t, saved.mem, c.mem)
+	assert.Equal(t, saved.pc, c.pc)
+	assert.Equal(t, saved.v, c.v)
+	assert.Equal(t, saved.depth, c.depth)
+	assert.Equal(t, uint8(1), c.depth)
+	assert.Equal(t, "20C-00EE RET  \n", out.String())
+}
+
+func TestDebuggerStepOverOut(t *testing.T) {
+	var out bytes.Buffer
+	d := NewDebugger(&out, nil)
+	c := NewChip8(debuggerROM, QuirkPresets["default"])
+	c.step()
+
+	// CALL at 202 runs the whole subroutine
+	d.StepOver(c)
+	assert.True(t, run(d, c, 100))
+	assert.Equal(t, uint16(0x204), c.pc)
+	assert.Equal(t, uint8(1), c.v[0])
+
+	// anything else is a single step
+	d.StepOver(c)
+	assert.True(t, run(d, c, 100))
+	assert.Equal(t, uint16(0x202), c.pc)
+
+	assert.EqualError(t, d.StepOut(c), "not in a subroutine")
+	c.step()
+	c.step()
+	assert.NoError(t, d.StepOut(c))
+	assert.True(t, run(d, c, 100))
+	assert.Equal(t, uint16(0x204), c.pc)
+	assert.Equal(t, uint8(0), c.depth)
+}
+
+func TestDebuggerRunToAndFrames(t *testing.T) {
+	var out bytes.Buffer
+	d := NewDebugger(&out, nil)
+	c := NewChip8(debuggerROM, QuirkPresets["default"])
+
+	d.RunTo(0x20A)
+	assert.True(t, run(d, c, 100))
+	assert.Equal(t, uint16(0x20A), c.pc)
+
+	d.Exec(c, "frames 2")
+	assert.True(t, run(d, c, 100))
+	assert.Equal(t, uint64(4+2*CyclesPerFrame), c.cycles)
+}
+
+func TestDebuggerScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script")
+	script := "# counts are decimal\nstep 100\nset v3=10\nstep 1\ncontinue\n"
+	assert.NoError(t, os.WriteFile(path, []byte(script), 0644))
+
+	m := newMachine(debuggerROM, true, QuirkPresets["default"])
+	var out bytes.Buffer
+	m.log, m.debugger.out = &out, &out
+	assert.NoError(t, m.RunScript(path))
+	// every step ran before the next line
+	assert.Equal(t, uint64(101), m.chip8.cycles)
+	assert.Equal(t, uint8(0x10), m.chip8.v[3])
+	assert.False(t, m.stepMode)
+	assert.Contains(t, out.String(), "> step 100\n")
+}
@@ -0,0 +1,119 @@
+package emulator
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+const maxDiffLines = 16
+
+// Divergence is the first instruction after which two machines disagree.
+type Divergence struct {
+	Cycle  int
+	Frame  int
+	PC     uint16
+	Opcode uint16
+	Disasm string
+	Diffs  []string
+}
+
+// Lockstep runs the same rom and inputs on two quirk profiles and compares the
+// full machine state after every instruction. It returns nil if the machines
+// agree for the given number of frames.
+func Lockstep(rom []byte, qa, qb Quirks, frames int, input func(c *Chip8, frame int)) *Divergence {
+	a := NewChip8(rom, qa)
+	b := NewChip8(rom, qb)
+	seed := rand.Int63()
+	a.rand.Seed(seed)
+	b.rand.Seed(seed)
+
+	cycle := 0
+	for f := 0; f < frames; f++ {
+		if input != nil {
+			input(a, f)
+			input(b, f)
+		}
+		for i := 0; i < CyclesPerFrame; i++ {
+			pc := a.pc
+			op := a.opcodeAt(pc)
+			a.step()
+			b.step()
+			cycle++
+
+			if diffs := diffState(a, b); len(diffs) > 0 {
+				return &Divergence{Cycle: cycle, Frame: f, PC: pc, Opcode: op, Disasm: disassemble(op, a.quirks), Diffs: diffs}
+			}
+		}
+		a.decrementTimer()
+		b.decrementTimer()
+	}
+	return nil
+}
+
+func (d *Divergence) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "diverged at cycle %d (frame %d)\n", d.Cycle, d.Frame)
+	fmt.Fprintf(&s, "  %03X-%04X %s\n", d.PC, d.Opcode, d.Disasm)
+	for _, l := range d.Diffs {
+		fmt.Fprintf(&s, "  %s\n", l)
+	}
+	return s.String()
+}
+
+func diffState(a, b *Chip8) []string {
+	diffs := []string{}
+	add := func(format string, args ...interface{}) {
+		diffs = append(diffs, fmt.Sprintf(format, args...))
+	}
+
+	if a.pc != b.pc {
+		add("PC: %03X != %03X", a.pc, b.pc)
+	}
+	for i := range a.v {
+		if a.v[i] != b.v[i] {
+			add("V%X: %02X != %02X", i, a.v[i], b.v[i])
+		}
+	}
+	if a.i != b.i {
+		add(" I: %04X != %04X", a.i, b.i)
+	}
+	if a.dt != b.dt {
+		add("DT: %02X != %02X", a.dt, b.dt)
+	}
+	if a.st != b.st {
+		add("ST: %02X != %02X", a.st, b.st)
+	}
+	if a.sp != b.sp {
+		add("SP: %02X != %02X", a.sp, b.sp)
+	}
+	for i := range a.stack {
+		if a.stack[i] != b.stack[i] {
+			add("stack[%X]: %03X != %03X", i, a.stack[i], b.stack[i])
+		}
+	}
+
+	mem := 0
+	for i := range a.mem {
+		if a.mem[i] != b.mem[i] {
+			if mem < maxDiffLines {
+				add("mem[%03X]: %02X != %02X", i, a.mem[i], b.mem[i])
+			}
+			mem++
+		}
+	}
+	if mem > maxDiffLines {
+		add("... %d more memory bytes differ", mem-maxDiffLines)
+	}
+
+	pixels := 0
+	for i := range a.disp {
+		if a.disp[i] != b.disp[i] {
+			pixels++
+		}
+	}
+	if pixels > 0 {
+		add("display: %d pixels differ", pixels)
+	}
+	return diffs
+}
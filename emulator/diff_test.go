@@ -0,0 +1,29 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockstepSameQuirks(t *testing.T) {
+	q := QuirkPresets["vip"]
+	assert.Nil(t, Lockstep(quirksROM, q, q, 20, nil))
+}
+
+func TestLockstepRandomIsShared(t *testing.T) {
+	rom := assemble(0xC0FF, 0xC1FF, 0x1200)
+	q := QuirkPresets["default"]
+	assert.Nil(t, Lockstep(rom, q, q, 20, nil))
+}
+
+func TestLockstepDivergence(t *testing.T) {
+	d := Lockstep(quirksROM, QuirkPresets["default"], QuirkPresets["vip"], 20, nil)
+	if assert.NotNil(t, d) {
+		assert.Equal(t, 3, d.Cycle)
+		assert.Equal(t, uint16(0x204), d.PC)
+		assert.Equal(t, uint16(0x8011), d.Opcode)
+		assert.Equal(t, "OR   V0,V1", d.Disasm)
+		assert.Equal(t, []string{"VF: 05 != 00"}, d.Diffs)
+	}
+}
@@ -0,0 +1,227 @@
+package emulator
+
+import (
+	"fmt"
+)
+
+// disassemble returns the mnemonic of op under q, or "" if op is not an
+// instruction.
+func disassemble(op uint16, q Quirks) string {
+	h := op & 0xF000
+	nnn := op & 0x0FFF
+	nn := uint8(nnn & 0xff)
+	x := uint8((nnn >> 8) & 0xf)
+	y := uint8((nnn >> 4) & 0xf)
+	n := nn & 0x0f
+
+	switch h {
+	case 0x0000:
+		switch op {
+		case 0x00E0: // clear display
+			return fmt.Sprintf("CLS  ")
+
+		case 0x00EE: // return from subroutine
+			return fmt.Sprintf("RET  ")
+
+		default: // 0NNN machine code routines are ignored
+			return fmt.Sprintf("SYS  %03X", nnn)
+		}
+	case 0x1000: // goto 0x0NNN
+		return fmt.Sprintf("GOTO %03X", nnn)
+
+	case 0x2000: // call 0x0NNN
+		return fmt.Sprintf("CALL %03X", nnn)
+
+	case 0x3000: // 0x3XNN if(Vx==NN)
+		return fmt.Sprintf("SE   V%0X,#%02X", x, nn)
+
+	case 0x4000: // 0x4XNN if(Vx!=NN)
+		return fmt.Sprintf("SNE  V%0X,#%02X", x, nn)
+
+	case 0x5000: // 0x5XY0 if(Vx==Vy)
+		return fmt.Sprintf("SE   V%0X,V%0X", x, y)
+
+	case 0x6000: // 6XNN Vx = NN
+		return fmt.Sprintf("LD   V%0X,#%02X", x, nn)
+
+	case 0x7000: // 7XNN Vx += NN (Carry flag is not changed)
+		return fmt.Sprintf("ADD  V%0X,#%02X", x, nn)
+
+	case 0x8000:
+		switch nnn & 0xf {
+		case 0: // 8XY0	Vx=Vy
+			return fmt.Sprintf("LD   V%0X,V%0X", x, y)
+
+		case 1: // 8XY1	Vx=Vx|Vy
+			return fmt.Sprintf("OR   V%0X,V%0X", x, y)
+
+		case 2: // 8XY2	Vx=Vx&Vy
+			return fmt.Sprintf("AND  V%0X,V%0X", x, y)
+
+		case 3: // 8XY3	Vx=Vx^Vy
+			return fmt.Sprintf("XOR  V%0X,V%0X", x, y)
+
+		case 4: // 8XY4	Vx += Vy
+			return fmt.Sprintf("ADD  V%0X,V%0X", x, y)
+
+		case 5: // 8XY5	Vx -= Vy
+			return fmt.Sprintf("SUB  V%0X,V%0X", x, y)
+
+		case 6: // 8XY6	Vx>>=1
+			return fmt.Sprintf("SHR  V%0X", x)
+
+		case 7: // 8XY7	Vx=Vy-Vx
+			return fmt.Sprintf("SUBN V%0X,V%0X", x, y)
+
+		case 0xE: // 8XYE Vx<<=1
+			return fmt.Sprintf("SHL  V%0X", x)
+		}
+	case 0x9000: // 9XY0 if(Vx!=Vy)
+		return fmt.Sprintf("SNE  V%0X,V%0X", x, y)
+
+	case 0xA000: // ANNN I = NNN
+		return fmt.Sprintf("LD   I,#%04X", nnn)
+
+	case 0xB000: // BNNN PC=V0+NNN, or VX+NNN with the jump quirk
+		if q.Jump {
+			return fmt.Sprintf("JP   V%0X,#%04X", x, nnn)
+		}
+		return fmt.Sprintf("JP   V0,#%04X", nnn)
+
+	case 0xC000: // CXNN Vx=rand()&NN
+		return fmt.Sprintf("RND  V%0X,#%02X", x, nn)
+
+	case 0xD000: // DXYN draw(Vx,Vy,N)
+		return fmt.Sprintf("DRW  V%0X,V%0X,%d", x, y, n)
+
+	case 0xE000:
+		switch nn {
+		case 0x9E: // EX9E if(key()==Vx)
+			return fmt.Sprintf("SKP  V%0X", x)
+
+		case 0xA1: // EXA1 if(key()!=Vx)
+			return fmt.Sprintf("SKNP V%0X", x)
+		}
+	case 0xF000:
+		switch nn {
+		case 0x07: // FX07 Vx = get_delay()
+			return fmt.Sprintf("LD   V%0X,DT", x)
+
+		case 0x0A: // FX0A Vx = get_key()
+			return fmt.Sprintf("LD   V%0X,K", x)
+
+		case 0x15: // FX15 delay_timer(Vx)
+			return fmt.Sprintf("LD   DT,V%0X", x)
+
+		case 0x18: // FX18 sound_timer(Vx)
+			return fmt.Sprintf("LD   ST,V%0X", x)
+
+		case 0x1E: // FX1E I +=Vx
+			return fmt.Sprintf("ADD  I,V%0X", x)
+
+		case 0x29: // FX29 I=sprite_addr[Vx]
+			return fmt.Sprintf("LD   F,V%0X", x)
+
+		case 0x33: // FX33 set_BCD(Vx);
+			return fmt.Sprintf("LD   B,V%0X", x)
+
+		case 0x55: // FX55 reg_dump(Vx,&I)
+			return fmt.Sprintf("LD   [I],V%0X", x)
+
+		case 0x65: // FX65 reg_load(Vx,&I)
+			return fmt.Sprintf("LD   V%0X,[I]", x)
+		}
+	}
+	return ""
+}
+
+// reachable follows jumps, calls and skips from ProgramOffset and marks the
+// addresses that statically look like instructions.
+func reachable(mem []byte) [4096]bool {
+	code := [4096]bool{}
+	work := []uint16{ProgramOffset}
+	for len(work) > 0 {
+		addr := work[len(work)-1] & AddressMask
+		work = work[:len(work)-1]
+
+	trace:
+		for !code[addr] {
+			op := uint16(mem[addr])<<8 | uint16(mem[(addr+1)&AddressMask])
+			if disassemble(op, Quirks{}) == "" || (op&0xF000 == 0 && op != 0x00E0 && op != 0x00EE) {
+				break
+			}
+			code[addr] = true
+
+			switch {
+			case op == 0x00EE, op&0xF000 == 0xB000:
+				// return or computed jump, the next address is unknown
+				break trace
+			case op&0xF000 == 0x1000:
+				addr = op & 0x0FFF
+				continue
+			case op&0xF000 == 0x2000:
+				work = append(work, op&0x0FFF)
+			case op&0xF000 == 0x3000, op&0xF000 == 0x4000, op&0xF000 == 0x5000, op&0xF000 == 0x9000,
+				op&0xF0FF == 0xE09E, op&0xF0FF == 0xE0A1:
+				work = append(work, (addr+4)&AddressMask)
+			}
+			addr = (addr + 2) & AddressMask
+		}
+	}
+	return code
+}
+
+// branchTarget returns where op at pc may continue other than pc+2: the
+// destination of JP and CALL, or the instruction skipped to.
+func branchTarget(pc, op uint16) (uint16, bool) {
+	switch {
+	case op&0xF000 == 0x1000, op&0xF000 == 0x2000:
+		return op & 0x0FFF, true
+	case op&0xF000 == 0x3000, op&0xF000 == 0x4000, op&0xF000 == 0x5000, op&0xF000 == 0x9000,
+		op&0xF0FF == 0xE09E, op&0xF0FF == 0xE0A1:
+		return (pc + 4) & AddressMask, true
+	}
+	return 0, false
+}
+
+type disasmLine struct {
+	addr       uint16
+	text       string
+	current    bool // at pc
+	target     bool // branch target of the instruction at pc
+	breakpoint bool
+}
+
+// disasmWindow decodes rows instructions around center without executing
+// them, with center on the row before the middle.
+func (c *Chip8) disasmWindow(center uint16, rows int, sym *Symbols, breakpoints map[uint16]bool) []disasmLine {
+	target, branch := branchTarget(c.pc, c.opcodeAt(c.pc))
+	addr := center - uint16((rows-1)/2*2)
+	lines := make([]disasmLine, rows)
+	for i := range lines {
+		a := addr & AddressMask
+		lines[i] = disasmLine{
+			addr:       a,
+			text:       formatInstruction(a, c.opcodeAt(a), sym, c.quirks),
+			current:    a == c.pc,
+			target:     branch && a == target,
+			breakpoint: breakpoints[a],
+		}
+		addr += 2
+	}
+	return lines
+}
+
+// String formats the line with a gutter: '*' breakpoint, '>' pc, '<' target.
+func (l disasmLine) String() string {
+	gutter := []byte("  ")
+	if l.breakpoint {
+		gutter[0] = '*'
+	}
+	if l.current {
+		gutter[1] = '>'
+	} else if l.target {
+		gutter[1] = '<'
+	}
+	return string(gutter) + l.text
+}
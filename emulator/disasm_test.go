@@ -0,0 +1,55 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisasmWindow(t *testing.T) {
+	c := NewChip8(assemble(0x6005, 0x3005, 0x1200, 0x00E0), QuirkPresets["default"])
+	c.step()
+
+	lines := c.disasmWindow(c.pc, 5, nil, map[uint16]bool{0x204: true})
+	text := []string{}
+	for _, l := range lines {
+		text = append(text, l.String())
+	}
+	assert.Equal(t, []string{
+		"  1FE-0000 SYS  000",
+		"  200-6005 LD   V0,#05",
+		" >202-3005 SE   V0,#05",
+		"* 204-1200 GOTO 200",
+		" <206-00E0 CLS  ",
+	}, text)
+}
+
+func TestBranchTarget(t *testing.T) {
+	for _, tt := range []struct {
+		op     uint16
+		target uint16
+		ok     bool
+	}{
+		{0x1234, 0x234, true},
+		{0x2ABC, 0xABC, true},
+		{0x4100, 0x304, true},
+		{0xE3A1, 0x304, true},
+		{0xB200, 0, false},
+		{0x00EE, 0, false},
+		{0x6100, 0, false},
+	} {
+		target, ok := branchTarget(0x300, tt.op)
+		assert.Equal(t, tt.ok, ok, "%04X", tt.op)
+		assert.Equal(t, tt.target, target, "%04X", tt.op)
+	}
+}
+
+func TestDisassembleJump(t *testing.T) {
+	assert.Equal(t, "JP   V0,#0300", disassemble(0xB300, QuirkPresets["vip"]))
+	assert.Equal(t, "JP   V3,#0300", disassemble(0xB300, QuirkPresets["schip"]))
+
+	// the trace shows the register the jump used
+	c := NewChip8(assemble(0x6302, 0xB300), QuirkPresets["schip"])
+	c.step()
+	assert.Equal(t, "JP   V3,#0300", c.execOpcode(c.fetchOpcode()))
+}
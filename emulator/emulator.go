@@ -1,13 +1,11 @@
 package emulator
 
 import (
-	"encoding/binary"
+	"bufio"
 	"fmt"
-	"log"
-	"math"
-
-	"github.com/veandco/go-sdl2/img"
-	"github.com/veandco/go-sdl2/sdl"
+	"io"
+	"os"
+	"time"
 )
 
 const (
@@ -20,239 +18,264 @@ const (
 	InformationH    = WindowH - EmulatorH
 	FontSize        = 16
 	FontPerW        = 32
-	AudioSamples    = 64
+	AudioSamples    = 512
+	HistoryChars    = (EmulatorW/2 + 48) / FontSize
+	PanelRows       = InformationH / FontSize
 )
 
+// Emulator runs a machine on a Frontend, one frame at a time.
 type Emulator struct {
-	rom      []byte
-	chip8    *Chip8
-	renderer *sdl.Renderer
-	audio    sdl.AudioDeviceID
-	font     *sdl.Texture
+	*machine
+	frontend Frontend
 	running  bool
+	quit     chan struct{}
 	focus    bool
-	stepMode bool
-}
+	commands chan string
 
-var scanCode2Key = map[int]byte{
-	sdl.SCANCODE_4: 0x1,
-	sdl.SCANCODE_5: 0x2,
-	sdl.SCANCODE_6: 0x3,
-	sdl.SCANCODE_7: 0xc,
-	sdl.SCANCODE_R: 0x4,
-	sdl.SCANCODE_T: 0x5,
-	sdl.SCANCODE_Y: 0x6,
-	sdl.SCANCODE_U: 0xd,
-	sdl.SCANCODE_F: 0x7,
-	sdl.SCANCODE_G: 0x8,
-	sdl.SCANCODE_H: 0x9,
-	sdl.SCANCODE_J: 0xe,
-	sdl.SCANCODE_V: 0xa,
-	sdl.SCANCODE_B: 0x0,
-	sdl.SCANCODE_N: 0xb,
-	sdl.SCANCODE_M: 0xf,
-}
+	frames    int
+	maxFrames int
 
-func checkError(s string, e error) {
-	if e != nil {
-		log.Fatalf(s, e)
-	}
-}
+	screenshot     ScreenshotOptions
+	screenshotAt   int
+	screenshotPath string
 
-func initRenderer() *sdl.Renderer {
-	window, err := sdl.CreateWindow("Chip-8 Emulator", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, WindowW, WindowH, sdl.WINDOW_SHOWN)
-	checkError("CreateWindow", err)
+	palettes []Theme // the themes, after a custom palette if there is one
+	palette  int
 
-	renderer, err := sdl.CreateRenderer(window, -1, sdl.RENDERER_PRESENTVSYNC)
-	checkError("CreateRenderer", err)
+	synth      SynthOptions
+	record     RecordOptions
+	recorder   Recorder
+	recordWAV  *wavWriter
+	recordBeep *Synth
+}
 
-	// workaround for https://bugzilla.libsdl.org/show_bug.cgi?id=4272
-	// 	or update sdl2 to 2.0.9
-	window.Hide()
-	sdl.PumpEvents()
-	window.Show()
+func NewEmulator(b []byte, sm bool, q Quirks, f Frontend) *Emulator {
+	e := &Emulator{machine: newMachine(b, sm, q), frontend: f, running: true, quit: make(chan struct{}, 1), focus: true, palettes: Themes, synth: DefaultSynth}
+	if m, ok := f.Video.(MessageWriter); ok {
+		e.log = m.Messages()
+		e.debugger.out = e.log
+	}
+	return e
+}
 
-	return renderer
+// SetMaxFrames stops Run after n frames, 0 runs until the frontend quits.
+func (e *Emulator) SetMaxFrames(n int) {
+	e.maxFrames = n
 }
 
-func initAudio() sdl.AudioDeviceID {
-	want := &sdl.AudioSpec{
-		Freq:     AudioSamples * VBlankFrequency,
-		Format:   sdl.AUDIO_F32LSB,
-		Channels: 1,
-		Samples:  AudioSamples,
+// SetPalette colors the display with p, which the palette hotkey switches
+// away from and back to among the themes.
+func (e *Emulator) SetPalette(p Palette) {
+	e.palettes, e.palette = Themes, 0
+	for i, t := range Themes {
+		if t.Palette == p {
+			e.palette = i
+			return
+		}
 	}
-	have := &sdl.AudioSpec{}
-	audio, err := sdl.OpenAudioDevice("", false, want, have, sdl.AUDIO_ALLOW_ANY_CHANGE)
-	checkError("OpenAudioDevice", err)
-
-	sdl.PauseAudioDevice(audio, false)
-	return audio
+	e.palettes = append([]Theme{{"custom", p}}, Themes...)
 }
 
-func initFont(r *sdl.Renderer) *sdl.Texture {
-	surface, err := img.Load("image/font.png")
-	checkError("Load", err)
-	defer surface.Free()
+// Palette is the palette the frontends draw the display with.
+func (e *Emulator) Palette() Palette {
+	return e.palettes[e.palette].Palette
+}
 
-	texture, err := r.CreateTextureFromSurface(surface)
-	checkError("CreateTextureFromSurface", err)
+// SetScreenshot chooses how the screenshot hotkey and ScreenshotAt draw
+// the display.
+func (e *Emulator) SetScreenshot(o ScreenshotOptions) {
+	e.screenshot = o
+}
 
-	texture.SetBlendMode(sdl.BLENDMODE_ADD)
+// ScreenshotAt writes a screenshot to path after frame n.
+func (e *Emulator) ScreenshotAt(n int, path string) {
+	e.screenshotAt, e.screenshotPath = n, path
+}
 
-	return texture
+// StartConsole reads debugger commands from stdin. They run between two
+// instructions of the emulator loop.
+func (e *Emulator) StartConsole() {
+	e.commands = make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			e.commands <- scanner.Text()
+		}
+		close(e.commands)
+	}()
+	e.prompt()
 }
 
-func NewEmulator(b []byte, sm bool) *Emulator {
-	err := sdl.Init(sdl.INIT_EVERYTHING)
-	checkError("sdl.Init", err)
+func (e *Emulator) prompt() {
+	if e.commands != nil {
+		fmt.Fprint(e.log, "(chip8) ")
+	}
+}
 
-	renderer := initRenderer()
-	audio := initAudio()
-	font := initFont(renderer)
+func (e *Emulator) pollConsole() {
+	select {
+	case line, ok := <-e.commands:
+		if !ok {
+			e.commands = nil
+			return
+		}
+		if e.debugger.Exec(e.chip8, line) {
+			e.stepMode = false
+		}
+		e.prompt()
+	default:
+	}
+}
 
-	return &Emulator{rom: b, chip8: newChip8(b), renderer: renderer, audio: audio, font: font, running: true, focus: true, stepMode: sm}
+// Quit makes Run return after the current frame, e.g. on a signal. Unlike
+// the other methods it may be called from any goroutine.
+func (e *Emulator) Quit() {
+	select {
+	case e.quit <- struct{}{}:
+	default:
+	}
 }
 
+// Run runs frames until the frontend quits, Quit is called or the frame
+// limit is reached, then stops recording and closes the frontend.
 func (e *Emulator) Run() {
-	perVblankCycle := Chip8Frequency / VBlankFrequency
-	cycle := 0
-
 	for e.running {
-		cycle++
-		if e.focus && !e.stepMode {
-			e.chip8.step()
+		select {
+		case <-e.quit:
+			e.running = false
+			continue
+		default:
+		}
+		e.frame()
+		e.frames++
+		if e.frames == e.screenshotAt {
+			e.takeScreenshot(e.screenshotPath)
+		}
+		if e.recorder != nil {
+			e.recordFrame()
 		}
+		if e.maxFrames > 0 && e.frames >= e.maxFrames {
+			e.running = false
+		}
+	}
 
-		if cycle > perVblankCycle {
-			cycle = 0
-			e.draw()
+	e.stopRecording()
 
-			if e.focus {
-				e.updateSound()
-				e.chip8.decrementTimer()
+	// one device may be several parts of the frontend
+	closed := map[io.Closer]bool{}
+	for _, part := range []interface{}{e.frontend.Video, e.frontend.Audio, e.frontend.Input} {
+		if c, ok := part.(io.Closer); ok && !closed[c] {
+			closed[c] = true
+			if err := c.Close(); err != nil {
+				fmt.Fprintln(e.log, err)
 			}
 		}
-
-		e.pollEvents()
 	}
 }
 
-func (e *Emulator) draw() {
-	e.renderer.SetDrawColor(0, 0, 0, 255)
-	e.renderer.Clear()
+func (e *Emulator) frame() {
+	for _, ev := range e.frontend.Input.Poll(e) {
+		e.handle(ev)
+	}
+	e.pollConsole()
 
-	// chip8 display
-	e.renderer.SetDrawColor(0, 255, 0, 255)
-	for y := int32(0); y < Chip8DisplayH; y++ {
-		for x := int32(0); x < Chip8DisplayW; x++ {
-			if e.chip8.disp[y*Chip8DisplayW+x] != 0 {
-				e.renderer.FillRect(&sdl.Rect{X: x * DisplayScale, Y: y * DisplayScale, W: DisplayScale, H: DisplayScale})
-			}
+	// commands from the console run even though the window lost the focus
+	if e.focus || e.debugger.pending() {
+		for i := 0; i < CyclesPerFrame && !e.stepMode; i++ {
+			e.step()
 		}
 	}
+	if e.focus {
+		e.chip8.endFrame()
+	}
 
-	e.drawDebugInfo()
+	e.frontend.Audio.Tone(e.focus && e.chip8.st > 0)
+	e.frontend.Video.Present(e)
+}
 
-	e.renderer.Present()
+func (e *Emulator) step() {
+	if e.machine.step() {
+		e.prompt()
+	}
 }
 
-func (e *Emulator) pollEvents() {
-	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
-		switch ev := event.(type) {
-		case *sdl.QuitEvent:
-			e.running = false
-		case *sdl.KeyboardEvent:
-			switch ev.Type {
-			case sdl.KEYDOWN:
-				if i, ok := scanCode2Key[int(ev.Keysym.Scancode)]; ok {
-					e.chip8.keys[i] = 1
-				} else {
-					if ev.Keysym.Scancode == sdl.SCANCODE_SPACE {
-						if e.stepMode {
-							e.chip8.step()
-						} else {
-							e.stepMode = true
-						}
-					} else if ev.Keysym.Scancode == sdl.SCANCODE_RETURN {
-						if e.stepMode {
-							e.stepMode = false
-						}
-					} else if ev.Keysym.Scancode == sdl.SCANCODE_Z {
-						e.chip8 = newChip8(e.rom)
-					}
-				}
-			case sdl.KEYUP:
-				if i, ok := scanCode2Key[int(ev.Keysym.Scancode)]; ok {
-					e.chip8.keys[i] = 0
-				}
-			}
-		case *sdl.WindowEvent:
-			switch ev.Event {
-			case sdl.WINDOWEVENT_FOCUS_LOST:
-				e.focus = false
-			case sdl.WINDOWEVENT_FOCUS_GAINED:
-				e.focus = true
-			}
-		}
+func (e *Emulator) handle(ev Event) {
+	switch ev.Kind {
+	case EventKeyDown:
+		e.chip8.keys[ev.Key&0xf] = 1
+	case EventKeyUp:
+		e.chip8.keys[ev.Key&0xf] = 0
+	case EventHotkey:
+		e.hotkey(ev.Hotkey, ev.Addr)
+	case EventFocusLost:
+		e.focus = false
+	case EventFocusGained:
+		e.focus = true
+	case EventQuit:
+		e.running = false
 	}
 }
 
-func (e *Emulator) updateSound() {
-	if e.chip8.st > 0 {
-		samples := make([]byte, 4*AudioSamples)
-		for i := 0; i < len(samples); i += 4 {
-			// sin wave
-			f := 2.0 * math.Pi / 180.0 * float64(360*i/AudioSamples)
-			f = math.Sin(f)
-			binary.LittleEndian.PutUint32(samples[i:], math.Float32bits(float32(f)))
+func (e *Emulator) hotkey(h Hotkey, addr uint16) {
+	switch h {
+	case HotkeyStep:
+		if e.stepMode {
+			e.step()
+		} else {
+			e.stepMode = true
 		}
-
-		err := sdl.QueueAudio(e.audio, samples)
-		if err != nil {
-			fmt.Println(err)
+	case HotkeyResume:
+		e.stepMode = false
+	case HotkeyReset:
+		e.reset()
+	case HotkeyRunTo:
+		e.debugger.RunTo(addr)
+		e.stepMode = false
+	case HotkeyToggleBreakpoint:
+		e.debugger.toggleBreakpoint(addr)
+	case HotkeyScreenshot:
+		e.takeScreenshot(screenshotName(time.Now()))
+	case HotkeyRecord:
+		e.toggleRecording(time.Now())
+	case HotkeyPalette:
+		e.palette = (e.palette + 1) % len(e.palettes)
+		fmt.Fprintln(e.log, "palette", e.palettes[e.palette].Name)
+	case HotkeyQuit:
+		e.running = false
+	default:
+		if e.stepMode {
+			e.runHotkey(h)
 		}
 	}
 }
 
-func (e *Emulator) drawDebugInfo() {
-	e.renderer.SetDrawColor(32, 32, 32, 255)
-	e.renderer.FillRect(&sdl.Rect{X: 0, Y: EmulatorH, W: EmulatorW, H: InformationH})
-
-	// draw opcodes history
-	offsetX := 0
-	for i := 0; i < OpHistoryNum; i++ {
-		e.drawText(e.chip8.ophistory[(e.chip8.ophistoryIndex+i)%OpHistoryNum], 0, EmulatorH+i*FontSize)
+func (e *Emulator) takeScreenshot(path string) {
+	o := e.screenshot
+	if o.Palette == (Palette{}) {
+		o.Palette = e.Palette()
 	}
-
-	// draw v registers
-	offsetX = EmulatorW/2 + 48
-	for i, v := range e.chip8.v {
-		e.drawText(fmt.Sprintf("V%X = %02X", i, v), offsetX, EmulatorH+i*FontSize)
+	if err := e.saveScreenshot(path, o); err != nil {
+		fmt.Fprintln(e.log, "screenshot:", err)
+		return
 	}
-
-	// draw other registers
-	offsetX = EmulatorW - FontSize*9
-	e.drawText(fmt.Sprintf("DT = %02X", e.chip8.dt), offsetX, EmulatorH+FontSize*0)
-	e.drawText(fmt.Sprintf("ST = %02X", e.chip8.st), offsetX, EmulatorH+FontSize*1)
-	e.drawText(fmt.Sprintf("SP = %02X", e.chip8.sp), offsetX, EmulatorH+FontSize*2)
-	e.drawText(fmt.Sprintf(" I = %04X", e.chip8.i), offsetX, EmulatorH+FontSize*3)
-
-	// draw key inputs
-	keys := e.chip8.keys
-	e.drawText(fmt.Sprintf("KEYS %d%d%d%d", keys[0x01], keys[0x02], keys[0x03], keys[0x0c]), offsetX, EmulatorH+FontSize*5)
-	e.drawText(fmt.Sprintf("     %d%d%d%d", keys[0x04], keys[0x05], keys[0x06], keys[0x0d]), offsetX, EmulatorH+FontSize*6)
-	e.drawText(fmt.Sprintf("     %d%d%d%d", keys[0x07], keys[0x08], keys[0x09], keys[0x0e]), offsetX, EmulatorH+FontSize*7)
-	e.drawText(fmt.Sprintf("     %d%d%d%d", keys[0x0a], keys[0x00], keys[0x0b], keys[0x0f]), offsetX, EmulatorH+FontSize*8)
+	fmt.Fprintln(e.log, "screenshot saved to", path)
 }
 
-func (e *Emulator) drawText(s string, x, y int) {
-	for i, v := range []byte(s) {
-		v -= byte(' ')
-		fx := FontSize * (int32(v) % FontPerW)
-		fy := FontSize * (int32(v) / FontPerW)
-		e.renderer.Copy(e.font,
-			&sdl.Rect{X: fx, Y: fy, W: FontSize, H: FontSize},
-			&sdl.Rect{X: int32(x + i*FontSize), Y: int32(y), W: FontSize, H: FontSize})
+// runHotkey handles the run hotkeys of step mode: step over, step out and
+// run a frame.
+func (e *Emulator) runHotkey(h Hotkey) {
+	switch h {
+	case HotkeyStepOver:
+		e.debugger.StepOver(e.chip8)
+	case HotkeyStepOut:
+		if err := e.debugger.StepOut(e.chip8); err != nil {
+			fmt.Fprintln(e.log, err)
+			return
+		}
+	case HotkeyRunFrame:
+		e.debugger.RunFrames(e.chip8, 1)
+	default:
+		return
 	}
+	e.stepMode = false
 }
@@ -0,0 +1,88 @@
+package emulator
+
+import "io"
+
+// Video shows the machine. Present is called once per frame and paces the
+// emulator: it returns when the next frame is due.
+type Video interface {
+	Present(e *Emulator)
+}
+
+// Audio plays the buzzer. Tone is called once per frame with whether the
+// sound timer is running.
+type Audio interface {
+	Tone(on bool)
+}
+
+// Input returns the events that arrived since the last frame.
+type Input interface {
+	Poll(e *Emulator) []Event
+}
+
+// MessageWriter is a Video that shows the status messages, stack faults and
+// debugger output itself, e.g. because it draws on the terminal they would
+// go to. Otherwise they go to stderr.
+type MessageWriter interface {
+	Messages() io.Writer
+}
+
+// Frontend is the set of devices an Emulator runs on. Parts that also
+// implement io.Closer are closed when Run returns.
+type Frontend struct {
+	Video Video
+	Audio Audio
+	Input Input
+}
+
+type EventKind int
+
+const (
+	EventKeyDown EventKind = iota // Key pressed
+	EventKeyUp                    // Key released
+	EventHotkey                   // Hotkey, with Addr for the hotkeys that need one
+	EventFocusLost
+	EventFocusGained
+	EventQuit
+)
+
+type Hotkey int
+
+const (
+	HotkeyStep             Hotkey = iota // enter step mode, or step when in it
+	HotkeyResume                         // leave step mode
+	HotkeyReset                          // reload the rom
+	HotkeyStepOver                       // step mode only
+	HotkeyStepOut                        // step mode only
+	HotkeyRunFrame                       // step mode only
+	HotkeyRunTo                          // run until pc is Addr
+	HotkeyToggleBreakpoint               // at Addr
+	HotkeyScreenshot                     // save the display to a PNG in the working directory
+	HotkeyRecord                         // start or stop recording to the working directory
+	HotkeyPalette                        // switch to the next palette
+	HotkeyQuit                           // end the run, for frontends without a window to close
+)
+
+type Event struct {
+	Kind   EventKind
+	Key    uint8
+	Hotkey Hotkey
+	Addr   uint16
+}
+
+type nullVideo struct{}
+
+func (nullVideo) Present(e *Emulator) {}
+
+type nullAudio struct{}
+
+func (nullAudio) Tone(on bool) {}
+
+type nullInput struct{}
+
+func (nullInput) Poll(e *Emulator) []Event { return nil }
+
+// NullFrontend shows nothing, plays nothing, reads no input and does not
+// pace the emulator, e.g. to trace or profile a rom as fast as possible.
+func NullFrontend() Frontend {
+	return Frontend{nullVideo{}, nullAudio{}, nullInput{}}
+}
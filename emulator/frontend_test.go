@@ -0,0 +1,109 @@
+package emulator
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testFrontend replays one list of events per frame and records what the
+// emulator presents and plays.
+type testFrontend struct {
+	events   [][]Event
+	frame    int
+	presents []uint16 // pc at each Present
+	tones    []bool
+	closed   int
+}
+
+func (f *testFrontend) Poll(e *Emulator) []Event {
+	if f.frame >= len(f.events) {
+		return nil
+	}
+	f.frame++
+	return f.events[f.frame-1]
+}
+
+func (f *testFrontend) Present(e *Emulator) { f.presents = append(f.presents, e.chip8.pc) }
+func (f *testFrontend) Tone(on bool)        { f.tones = append(f.tones, on) }
+func (f *testFrontend) Close() error        { f.closed++; return nil }
+
+func newTestEmulator(b []byte, sm bool, frames int, events ...[]Event) (*Emulator, *testFrontend) {
+	f := &testFrontend{events: events}
+	e := NewEmulator(b, sm, QuirkPresets["default"], Frontend{f, f, f})
+	e.SetMaxFrames(frames)
+	e.debugger.out, e.log = io.Discard, io.Discard
+	return e, f
+}
+
+func hotkey(h Hotkey) Event { return Event{Kind: EventHotkey, Hotkey: h} }
+
+// V1=3, ST=V1, loop: JP loop
+var toneROM = assemble(0x6103, 0xF118, 0x1204)
+
+func TestRunFrames(t *testing.T) {
+	e, f := newTestEmulator(toneROM, false, 5)
+	e.Run()
+	assert.Equal(t, 5, e.frames)
+	assert.Equal(t, []uint16{0x204, 0x204, 0x204, 0x204, 0x204}, f.presents)
+	assert.Equal(t, []bool{true, true, false, false, false}, f.tones)
+	assert.Equal(t, 1, f.closed)
+}
+
+func TestRunKeysAndQuit(t *testing.T) {
+	e, f := newTestEmulator(toneROM, false, 0,
+		[]Event{{Kind: EventKeyDown, Key: 0xa}, {Kind: EventKeyDown, Key: 0x3}},
+		[]Event{{Kind: EventKeyUp, Key: 0xa}},
+		[]Event{{Kind: EventQuit}})
+	e.Run()
+	assert.Equal(t, 3, e.frames)
+	assert.Equal(t, uint8(0), e.chip8.keys[0xa])
+	assert.Equal(t, uint8(1), e.chip8.keys[0x3])
+	assert.Len(t, f.presents, 3)
+}
+
+func TestRunStepHotkeys(t *testing.T) {
+	e, f := newTestEmulator(toneROM, true, 5,
+		nil,
+		[]Event{hotkey(HotkeyStep)},
+		[]Event{hotkey(HotkeyStep), hotkey(HotkeyStep)},
+		[]Event{hotkey(HotkeyResume)},
+		[]Event{hotkey(HotkeyStep)})
+	e.Run()
+	assert.Equal(t, []uint16{0x200, 0x202, 0x204, 0x204, 0x204}, f.presents)
+	assert.True(t, e.stepMode)
+}
+
+func TestRunFocus(t *testing.T) {
+	e, f := newTestEmulator(toneROM, false, 3,
+		[]Event{{Kind: EventFocusLost}},
+		nil,
+		[]Event{{Kind: EventFocusGained}})
+	e.Run()
+	assert.Equal(t, []uint16{0x200, 0x200, 0x204}, f.presents)
+	assert.Equal(t, []bool{false, false, true}, f.tones)
+}
+
+func TestRunBreakpointHotkeys(t *testing.T) {
+	e, f := newTestEmulator(toneROM, true, 3,
+		[]Event{{Kind: EventHotkey, Hotkey: HotkeyToggleBreakpoint, Addr: 0x204}},
+		[]Event{hotkey(HotkeyResume)},
+		[]Event{hotkey(HotkeyReset)})
+	e.Run()
+	assert.Equal(t, []uint16{0x200, 0x204, 0x200}, f.presents)
+	assert.True(t, e.stepMode)
+}
+
+func TestQuit(t *testing.T) {
+	e, f := newTestEmulator(toneROM, false, 0)
+	e.Quit()
+	e.Quit()
+	e.Run()
+	assert.Equal(t, 0, e.frames)
+	assert.Equal(t, 1, f.closed)
+
+	e, _ = newTestEmulator(toneROM, false, 0, nil, []Event{hotkey(HotkeyQuit)})
+	e.Run()
+	assert.Equal(t, 2, e.frames)
+}
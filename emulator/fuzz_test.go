@@ -0,0 +1,60 @@
+package emulator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const fuzzCycles = 2000
+
+func FuzzExec(f *testing.F) {
+	f.Add(quirksROM, uint16(0), uint8(0))
+	// I at the end of memory for FX33, FX55, FX65 and DXYN
+	f.Add(assemble(0xAFFF, 0xFF33, 0xFF55, 0xFF65, 0xD00F, 0x1200), uint16(0), uint8(0x02))
+	roms, _ := filepath.Glob("../games/*")
+	for _, path := range roms {
+		if b, err := os.ReadFile(path); err == nil {
+			f.Add(b, uint16(0x0001), uint8(0x1f))
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, rom []byte, keys uint16, quirks uint8) {
+		c := NewChip8(rom, Quirks{
+			VFReset: quirks&0x01 != 0,
+			Memory:  quirks&0x02 != 0,
+			Shift:   quirks&0x04 != 0,
+			Jump:    quirks&0x08 != 0,
+			Wrap:    quirks&0x10 != 0,
+		})
+		// the heatmap counts every access through I too
+		c.SetHeatmap(&Heatmap{})
+		for i := range c.keys {
+			c.keys[i] = uint8(keys>>uint(i)) & 1
+		}
+
+		for i := 0; i < fuzzCycles; i++ {
+			pc, i0 := c.pc, c.i
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Fatalf("panic at %03X with I=%03X: %v", pc, i0, r)
+					}
+				}()
+				c.step()
+			}()
+
+			if int(c.depth) > len(c.stack) {
+				t.Fatalf("stack depth %d after %03X", c.depth, pc)
+			}
+			for _, v := range c.disp {
+				if v > 1 {
+					t.Fatalf("invalid pixel after %03X: %d", pc, v)
+				}
+			}
+			if i%CyclesPerFrame == 0 {
+				c.decrementTimer()
+			}
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package emulator
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+)
+
+const (
+	HeatmapW     = 64 // 64x64 cells, one per address
+	HeatmapDecay = 0.95
+)
+
+// Heatmap counts reads, writes and instruction fetches per address. Besides
+// the totals it keeps a heat per access kind that decays every frame, so
+// recent activity stands out.
+type Heatmap struct {
+	reads, writes, execs [4096]uint64
+	heat                 [3][4096]float32 // read, write, exec
+}
+
+const (
+	heatRead = iota
+	heatWrite
+	heatExec
+)
+
+func (h *Heatmap) read(addr uint16) {
+	addr &= AddressMask
+	h.reads[addr]++
+	h.heat[heatRead][addr]++
+}
+
+func (h *Heatmap) write(addr uint16) {
+	addr &= AddressMask
+	h.writes[addr]++
+	h.heat[heatWrite][addr]++
+}
+
+func (h *Heatmap) exec(addr uint16) {
+	addr &= AddressMask
+	h.execs[addr]++
+	h.heat[heatExec][addr]++
+}
+
+// Counts returns the total reads, writes and instruction fetches of addr.
+func (h *Heatmap) Counts(addr uint16) (reads, writes, execs uint64) {
+	addr &= AddressMask
+	return h.reads[addr], h.writes[addr], h.execs[addr]
+}
+
+func (h *Heatmap) decay() {
+	for k := range h.heat {
+		for a := range h.heat[k] {
+			h.heat[k][a] *= HeatmapDecay
+		}
+	}
+}
+
+// recentColor is the color of addr from the decaying heat: writes red,
+// fetches green and reads blue, each saturating as the heat grows.
+func (h *Heatmap) recentColor(addr uint16) color.RGBA {
+	level := func(v float32) uint8 {
+		return uint8(255 * v / (v + 1))
+	}
+	return color.RGBA{level(h.heat[heatWrite][addr]), level(h.heat[heatExec][addr]), level(h.heat[heatRead][addr]), 255}
+}
+
+// WritePNG writes the totals as a HeatmapW x HeatmapW image, address 0 at
+// the top left, every cell scale x scale pixels. Counts are shown on a log
+// scale relative to the busiest address of each kind.
+func (h *Heatmap) WritePNG(w io.Writer, scale int) error {
+	var maxReads, maxWrites, maxExecs uint64
+	for a := range h.reads {
+		maxReads = max64(maxReads, h.reads[a])
+		maxWrites = max64(maxWrites, h.writes[a])
+		maxExecs = max64(maxExecs, h.execs[a])
+	}
+	level := func(v, max uint64) uint8 {
+		if max == 0 {
+			return 0
+		}
+		return uint8(255 * math.Log1p(float64(v)) / math.Log1p(float64(max)))
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, HeatmapW*scale, HeatmapW*scale))
+	for a := range h.reads {
+		x, y := a%HeatmapW*scale, a/HeatmapW*scale
+		c := color.RGBA{level(h.writes[a], maxWrites), level(h.execs[a], maxExecs), level(h.reads[a], maxReads), 255}
+		fill(img, image.Rect(x, y, x+scale, y+scale), c)
+	}
+	return png.Encode(w, img)
+}
+
+func max64(a, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
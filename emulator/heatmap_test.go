@@ -0,0 +1,54 @@
+package emulator
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeatmapCounts(t *testing.T) {
+	// I=0x300, BCD of V0 to 0x300-0x302, load V0-V1 back, draw 2 rows from 0x300
+	c := NewChip8(assemble(0xA300, 0x6080, 0xF033, 0xF165, 0xD012, 0x120A), QuirkPresets["default"])
+	h := &Heatmap{}
+	c.SetHeatmap(h)
+	for i := 0; i < 8; i++ {
+		c.step()
+	}
+
+	r, w, x := h.Counts(0x300)
+	assert.Equal(t, []uint64{2, 1, 0}, []uint64{r, w, x})
+	r, w, x = h.Counts(0x302)
+	assert.Equal(t, []uint64{0, 1, 0}, []uint64{r, w, x})
+	r, w, x = h.Counts(0x20A)
+	assert.Equal(t, []uint64{0, 0, 3}, []uint64{r, w, x})
+	r, w, x = h.Counts(0x20B)
+	assert.Equal(t, []uint64{0, 0, 3}, []uint64{r, w, x})
+
+	before := h.recentColor(0x20A)
+	c.endFrame()
+	assert.True(t, h.recentColor(0x20A).G < before.G)
+	assert.Equal(t, uint8(0), before.R)
+}
+
+func TestHeatmapPNG(t *testing.T) {
+	h := &Heatmap{}
+	h.exec(0x200)
+	h.write(0xFFF)
+
+	var b bytes.Buffer
+	if !assert.NoError(t, h.WritePNG(&b, 2)) {
+		return
+	}
+	img, err := png.Decode(&b)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, HeatmapW*2, img.Bounds().Dx())
+
+	r, g, _, _ := img.At(0x200%HeatmapW*2, 0x200/HeatmapW*2).RGBA()
+	assert.Equal(t, []uint32{0, 0xffff}, []uint32{r, g})
+	r, g, _, _ = img.At(HeatmapW*2-1, HeatmapW*2-1).RGBA()
+	assert.Equal(t, []uint32{0xffff, 0}, []uint32{r, g})
+}
@@ -0,0 +1,62 @@
+//go:build js && wasm
+// +build js,wasm
+
+package emulator
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+// RegisterJS sets globalThis[name] to an object that runs a chip8 from
+// JavaScript:
+//
+//	load(rom: Uint8Array, quirks?: string)  starts the rom, returns an Error on bad arguments
+//	runFrame(): boolean                     runs 1/60s, returns whether the buzzer sounds
+//	framebuffer(): Uint8Array               64x32 pixels row by row, 1 lit, 0 dark
+//	setKey(key: number, down: boolean)      presses or releases key 0-F
+//
+// The caller paces runFrame, e.g. with requestAnimationFrame.
+func RegisterJS(name string) {
+	c := NewChip8(nil, QuirkPresets["default"])
+	api := map[string]interface{}{
+		"load": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			if len(args) < 1 {
+				return jsError(errors.New("load: missing rom"))
+			}
+			rom := make([]byte, args[0].Get("length").Int())
+			js.CopyBytesToGo(rom, args[0])
+			name := "default"
+			if len(args) > 1 && args[1].Type() == js.TypeString {
+				name = args[1].String()
+			}
+			q, ok := QuirkPresets[name]
+			if !ok {
+				return jsError(errors.New("load: unknown quirks preset " + name))
+			}
+			c = NewChip8(rom, q)
+			return nil
+		}),
+		"runFrame": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			c.RunFrame()
+			return c.Sound()
+		}),
+		"framebuffer": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			b := js.Global().Get("Uint8Array").New(len(c.disp))
+			js.CopyBytesToJS(b, c.disp[:])
+			return b
+		}),
+		"setKey": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			if len(args) < 2 {
+				return jsError(errors.New("setKey: want key and down"))
+			}
+			c.SetKey(uint8(args[0].Int()), args[1].Truthy())
+			return nil
+		}),
+	}
+	js.Global().Set(name, js.ValueOf(api))
+}
+
+func jsError(err error) interface{} {
+	return js.Global().Get("Error").New(err.Error())
+}
@@ -0,0 +1,30 @@
+//go:build js && wasm
+// +build js,wasm
+
+package emulator
+
+import (
+	"syscall/js"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSAPI(t *testing.T) {
+	RegisterJS("chip8test")
+	api := js.Global().Get("chip8test")
+
+	rom := js.Global().Get("Uint8Array").New(len(toneROM))
+	js.CopyBytesToJS(rom, toneROM)
+	assert.True(t, api.Call("load", rom, "nope").InstanceOf(js.Global().Get("Error")))
+	assert.True(t, api.Call("load", rom, "vip").IsNull())
+
+	assert.True(t, api.Call("runFrame").Bool())
+	fb := api.Call("framebuffer")
+	assert.Equal(t, Chip8DisplayW*Chip8DisplayH, fb.Get("length").Int())
+
+	api.Call("setKey", 0xa, true)
+	api.Call("runFrame")
+	api.Call("runFrame")
+	assert.False(t, api.Call("runFrame").Bool())
+}
@@ -0,0 +1,132 @@
+package emulator
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// machine is the part of a running emulator that does not depend on the
+// frontend: the chip8, the hooks attached to it, step mode and the debugger.
+type machine struct {
+	rom      []byte
+	chip8    *Chip8
+	stepMode bool
+	quirks   Quirks
+	tracer   *Tracer
+	profiler *Profiler
+	coverage *Coverage
+	heatmap  *Heatmap
+	symbols  *Symbols
+
+	debugger          *Debugger
+	breakOnStackFault bool
+	log               io.Writer // faults and status messages, off stdout which may carry a recording
+}
+
+func newMachine(b []byte, sm bool, q Quirks) *machine {
+	m := &machine{rom: b, stepMode: sm, quirks: q, heatmap: &Heatmap{}}
+	m.log = os.Stderr
+	m.debugger = NewDebugger(m.log, nil)
+	m.reset()
+	return m
+}
+
+// SetTracer streams every executed instruction to t, also after a reset.
+func (m *machine) SetTracer(t *Tracer) {
+	m.tracer = t
+	m.chip8.tracer = t
+}
+
+// SetProfiler counts executed instructions into p, also after a reset.
+func (m *machine) SetProfiler(p *Profiler) {
+	m.profiler = p
+	m.chip8.profiler = p
+}
+
+// SetCoverage records executed addresses into cv, also after a reset.
+func (m *machine) SetCoverage(cv *Coverage) {
+	m.coverage = cv
+	m.chip8.coverage = cv
+}
+
+// SetHeatmap counts memory accesses into h instead of the heatmap the
+// panel starts with, also after a reset.
+func (m *machine) SetHeatmap(h *Heatmap) {
+	m.heatmap = h
+	m.chip8.heatmap = h
+}
+
+// SetSymbols labels addresses in the debug views.
+func (m *machine) SetSymbols(s *Symbols) {
+	m.symbols = s
+	m.debugger.symbols = s
+}
+
+// SetBreakOnStackFault enters step mode when a CALL overflows or a RET
+// underflows the stack.
+func (m *machine) SetBreakOnStackFault(b bool) {
+	m.breakOnStackFault = b
+}
+
+// SetBreakpoints enters step mode before executing any of addrs.
+func (m *machine) SetBreakpoints(addrs []uint16) {
+	for _, a := range addrs {
+		m.debugger.breakpoints[a&AddressMask] = true
+	}
+}
+
+// ScriptMaxSteps is the most instructions a line of a debugger script runs,
+// so an until that is never reached does not hang the start.
+const ScriptMaxSteps = 1 << 24
+
+// RunScript runs the debugger commands in path, one per line, each to the
+// end before the next.
+func (m *machine) RunScript(path string) error {
+	resume, err := m.debugger.ExecScript(m.chip8, path, m.runPending)
+	if resume {
+		m.stepMode = false
+	}
+	return err
+}
+
+// runPending runs the machine until the pending debugger command is done,
+// counting down the timers every CyclesPerFrame instructions.
+func (m *machine) runPending() error {
+	for n := 0; m.debugger.pending(); n++ {
+		if n == ScriptMaxSteps {
+			m.debugger.cancel()
+			return fmt.Errorf("still running after %d instructions", n)
+		}
+		m.step()
+		if m.chip8.cycles%CyclesPerFrame == 0 {
+			m.chip8.endFrame()
+		}
+	}
+	return nil
+}
+
+func (m *machine) reset() {
+	m.chip8 = NewChip8(m.rom, m.quirks)
+	m.chip8.tracer = m.tracer
+	m.chip8.profiler = m.profiler
+	m.chip8.coverage = m.coverage
+	m.chip8.heatmap = m.heatmap
+}
+
+// step runs one instruction and reports whether the debugger stopped there.
+func (m *machine) step() bool {
+	m.chip8.step()
+	if m.chip8.fault != "" {
+		fmt.Fprintln(m.log, m.chip8.fault)
+		if m.breakOnStackFault {
+			m.stepMode = true
+		}
+	}
+	if reason, stop := m.debugger.check(m.chip8); stop {
+		m.debugger.stop(m.chip8, reason)
+		m.stepMode = true
+		return true
+	}
+	return false
+}
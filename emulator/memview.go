@@ -0,0 +1,187 @@
+package emulator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	MemoryBytesPerRow = 8
+	MemoryRows        = PanelRows - 1 // below the header line
+)
+
+// what the memory cursor follows
+const (
+	FollowNone = iota
+	FollowI
+	FollowPC
+)
+
+// edit targets besides memory: V0-VF are 0x0-0xf
+const (
+	TargetMemory = -1
+	TargetI      = 0x10
+	TargetDT     = 0x11
+	TargetST     = 0x12
+	TargetNum    = 0x13
+)
+
+// memoryView is the state of the hex dump panel: a cursor into memory and
+// the byte or register being edited in step mode.
+type memoryView struct {
+	cursor  uint16
+	top     uint16
+	follow  int
+	target  int
+	editing bool
+	input   []byte
+}
+
+func newMemoryView() *memoryView {
+	return &memoryView{follow: FollowI, target: TargetMemory}
+}
+
+// update moves the cursor to I or PC when following and scrolls it into view.
+func (m *memoryView) update(c *Chip8) {
+	switch m.follow {
+	case FollowI:
+		m.cursor = c.i & AddressMask
+	case FollowPC:
+		m.cursor = c.pc & AddressMask
+	}
+
+	row := m.cursor / MemoryBytesPerRow * MemoryBytesPerRow
+	if row < m.top {
+		m.top = row
+	} else if row >= m.top+MemoryRows*MemoryBytesPerRow {
+		m.top = row - (MemoryRows-1)*MemoryBytesPerRow
+	}
+}
+
+// move shifts the memory cursor, or the selected register, by delta and
+// stops following.
+func (m *memoryView) move(delta int) {
+	if m.editing {
+		return
+	}
+	if m.target != TargetMemory {
+		m.target = (m.target + delta%TargetNum + TargetNum) % TargetNum
+		return
+	}
+	m.follow = FollowNone
+	m.cursor = uint16(int(m.cursor)+delta) & AddressMask
+}
+
+// moveRows moves the memory cursor by whole rows, or the selected register
+// by n.
+func (m *memoryView) moveRows(n int) {
+	if m.target != TargetMemory {
+		m.move(n)
+		return
+	}
+	m.move(n * MemoryBytesPerRow)
+}
+
+func (m *memoryView) setFollow(f int) {
+	if !m.editing {
+		m.follow = f
+	}
+}
+
+// toggleTarget switches between editing memory and editing registers.
+func (m *memoryView) toggleTarget() {
+	if m.editing {
+		return
+	}
+	if m.target == TargetMemory {
+		m.target = 0
+	} else {
+		m.target = TargetMemory
+	}
+}
+
+func (m *memoryView) beginEdit() {
+	m.editing = true
+	m.input = m.input[:0]
+}
+
+func (m *memoryView) cancelEdit() {
+	m.editing = false
+}
+
+func (m *memoryView) typeDigit(d byte) {
+	if len(m.input) < m.inputDigits() {
+		m.input = append(m.input, "0123456789ABCDEF"[d&0xf])
+	}
+}
+
+func (m *memoryView) backspace() {
+	if len(m.input) > 0 {
+		m.input = m.input[:len(m.input)-1]
+	}
+}
+
+func (m *memoryView) inputDigits() int {
+	if m.target == TargetI {
+		return 3
+	}
+	return 2
+}
+
+// commitEdit stores the typed value. An empty input leaves the value as is.
+func (m *memoryView) commitEdit(c *Chip8) {
+	m.editing = false
+	if len(m.input) == 0 {
+		return
+	}
+	v, _ := strconv.ParseUint(string(m.input), 16, 16)
+	switch m.target {
+	case TargetMemory:
+		c.mem[m.cursor&AddressMask] = uint8(v)
+	case TargetI:
+		c.i = uint16(v) & AddressMask
+	case TargetDT:
+		c.dt = uint8(v)
+	case TargetST:
+		c.st = uint8(v)
+	default:
+		c.v[m.target] = uint8(v)
+	}
+}
+
+// field returns the text to show for target: the typed input, padded with
+// '_', while it is being edited, value otherwise.
+func (m *memoryView) field(target int, value string) string {
+	if m.editing && m.target == target {
+		return string(m.input) + strings.Repeat("_", m.inputDigits()-len(m.input))
+	}
+	return value
+}
+
+func (m *memoryView) header() string {
+	follow := map[int]string{FollowNone: "", FollowI: " @I", FollowPC: " @PC"}[m.follow]
+	return fmt.Sprintf("MEM %03X%s", m.cursor, follow)
+}
+
+// row formats MemoryBytesPerRow bytes from addr as "200 00E0 A22A 600C 6108".
+func (m *memoryView) row(c *Chip8, addr uint16) string {
+	s := fmt.Sprintf("%03X", addr&AddressMask)
+	for j := 0; j < MemoryBytesPerRow; j++ {
+		if j%2 == 0 {
+			s += " "
+		}
+		a := (addr + uint16(j)) & AddressMask
+		if a == m.cursor {
+			s += m.field(TargetMemory, fmt.Sprintf("%02X", c.mem[a]))
+		} else {
+			s += fmt.Sprintf("%02X", c.mem[a])
+		}
+	}
+	return s
+}
+
+// memoryColumn returns the character column of byte j in a row.
+func memoryColumn(j int) int {
+	return 4 + 2*j + j/2
+}
@@ -0,0 +1,94 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryViewFollow(t *testing.T) {
+	c := NewChip8(assemble(0xA3F0, 0x1202), QuirkPresets["default"])
+	m := newMemoryView()
+
+	m.update(c)
+	assert.Equal(t, uint16(0x000), m.cursor)
+	assert.Equal(t, uint16(0x000), m.top)
+
+	c.step()
+	m.update(c)
+	assert.Equal(t, uint16(0x3F0), m.cursor)
+	assert.Equal(t, uint16(0x3F0-(MemoryRows-1)*MemoryBytesPerRow), m.top)
+	assert.Equal(t, "MEM 3F0 @I", m.header())
+
+	m.setFollow(FollowPC)
+	m.update(c)
+	assert.Equal(t, uint16(0x202), m.cursor)
+	assert.Equal(t, uint16(0x200), m.top)
+
+	m.moveRows(1)
+	m.update(c)
+	assert.Equal(t, FollowNone, m.follow)
+	assert.Equal(t, uint16(0x20A), m.cursor)
+	assert.Equal(t, "MEM 20A", m.header())
+}
+
+func TestMemoryViewRow(t *testing.T) {
+	c := NewChip8(assemble(0x00E0, 0xA22A, 0x600C, 0x6108), QuirkPresets["default"])
+	m := newMemoryView()
+	m.follow = FollowNone
+	m.cursor = 0x203
+	assert.Equal(t, "200 00E0 A22A 600C 6108", m.row(c, 0x200))
+
+	m.beginEdit()
+	m.typeDigit(0x4)
+	assert.Equal(t, "200 00E0 A24_ 600C 6108", m.row(c, 0x200))
+	m.typeDigit(0xf)
+	m.typeDigit(0x1)
+	m.commitEdit(c)
+	assert.Equal(t, "200 00E0 A24F 600C 6108", m.row(c, 0x200))
+	assert.Equal(t, "A2", m.row(c, 0x200)[memoryColumn(2):memoryColumn(3)])
+}
+
+func TestMemoryViewEditRegisters(t *testing.T) {
+	c := NewChip8(nil, QuirkPresets["default"])
+	m := newMemoryView()
+	m.toggleTarget()
+	m.moveRows(3)
+
+	m.beginEdit()
+	m.typeDigit(0xa)
+	m.backspace()
+	m.typeDigit(0x7)
+	assert.Equal(t, "7_", m.field(0x3, "00"))
+	m.commitEdit(c)
+	assert.Equal(t, uint8(0x07), c.v[3])
+
+	m.move(-4)
+	assert.Equal(t, TargetST, m.target)
+	m.move(-2)
+	assert.Equal(t, TargetI, m.target)
+	m.beginEdit()
+	for _, d := range []byte{0x3, 0x2, 0x1, 0x0} {
+		m.typeDigit(d)
+	}
+	m.commitEdit(c)
+	assert.Equal(t, uint16(0x321), c.i)
+
+	m.beginEdit()
+	m.typeDigit(0x1)
+	m.cancelEdit()
+	m.beginEdit()
+	m.commitEdit(c)
+	assert.Equal(t, uint16(0x321), c.i)
+}
+
+func TestRecentlyWritten(t *testing.T) {
+	c := NewChip8(assemble(0xA300, 0x6080, 0xF033), QuirkPresets["default"])
+	RunFrames(c, 1, nil)
+	assert.True(t, c.recentlyWritten(0x300))
+	assert.True(t, c.recentlyWritten(0x302))
+	assert.False(t, c.recentlyWritten(0x303))
+
+	RunFrames(c, 1, nil)
+	assert.False(t, c.recentlyWritten(0x300))
+}
@@ -577,7 +577,7 @@ func TestExecOpcodes(t *testing.T) {
 		t.Run(fmt.Sprintf("opcode[%04X]", test.opcode), func(t *testing.T) {
 			b := make([]byte, 0x100)
 			binary.BigEndian.PutUint16(b, test.opcode)
-			c := newChip8(b)
+			c := NewChip8(b, QuirkPresets["default"])
 
 			if test.before != nil {
 				test.before(c)
@@ -589,3 +589,25 @@ func TestExecOpcodes(t *testing.T) {
 		})
 	}
 }
+
+func TestStackFaults(t *testing.T) {
+	// RET with an empty stack
+	c := NewChip8(assemble(0x00EE), QuirkPresets["default"])
+	c.step()
+	assert.Equal(t, "RET at 200 with empty stack", c.fault)
+
+	// 17 nested calls
+	c = NewChip8(assemble(0x2202, 0x2204, 0x2206, 0x2208, 0x220A, 0x220C, 0x220E, 0x2210,
+		0x2212, 0x2214, 0x2216, 0x2218, 0x221A, 0x221C, 0x221E, 0x2220, 0x2222), QuirkPresets["default"])
+	for i := 0; i < 16; i++ {
+		c.step()
+		assert.Equal(t, "", c.fault)
+	}
+	assert.Equal(t, uint16(0x204), c.callStack()[14])
+	assert.Len(t, c.callStack(), 16)
+	assert.Equal(t, uint16(0x220), c.callStack()[0])
+	c.step()
+	assert.Equal(t, "CALL at 220 with full stack", c.fault)
+	c.step()
+	assert.Equal(t, "", c.fault)
+}
@@ -0,0 +1,153 @@
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Palette colors the chip8 display. Foreground is a lit pixel, which is
+// XO-CHIP's first plane; the second plane and pixels lit in both planes have
+// colors of their own.
+type Palette struct {
+	Foreground color.RGBA
+	Background color.RGBA
+	Plane2     color.RGBA
+	Both       color.RGBA
+}
+
+// Colors lists the color of each display value, the plane bits of a pixel:
+// Background, Foreground, Plane2, Both.
+func (p Palette) Colors() [4]color.RGBA {
+	return [4]color.RGBA{p.Background, p.Foreground, p.Plane2, p.Both}
+}
+
+// Color is the color of the display value v.
+func (p Palette) Color(v uint8) color.RGBA {
+	return p.Colors()[v&3]
+}
+
+// Theme is a built-in palette.
+type Theme struct {
+	Name string
+	Palette
+}
+
+// Themes are the built-in palettes in the order the palette hotkey cycles
+// through them.
+var Themes = []Theme{
+	{"classic", Palette{rgb(0x00ff00), rgb(0x000000), rgb(0x008000), rgb(0x80ff80)}},
+	{"amber", Palette{rgb(0xffb000), rgb(0x1a1000), rgb(0x805800), rgb(0xffe0a0)}},
+	{"white", Palette{rgb(0xffffff), rgb(0x000000), rgb(0x808080), rgb(0xc0c0c0)}},
+	// the greenish gray LCD of the HP48
+	{"lcd", Palette{rgb(0x1e2a1c), rgb(0x9aa888), rgb(0x5c6a52), rgb(0x000000)}},
+}
+
+// DefaultPalette is the classic theme, green on black.
+var DefaultPalette = Themes[0].Palette
+
+func rgb(v uint32) color.RGBA {
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}
+}
+
+// LookupTheme returns the palette of the theme called name.
+func LookupTheme(name string) (Palette, error) {
+	names := make([]string, 0, len(Themes))
+	for _, t := range Themes {
+		if t.Name == name {
+			return t.Palette, nil
+		}
+		names = append(names, t.Name)
+	}
+	return Palette{}, fmt.Errorf("unknown theme %q (%s)", name, strings.Join(names, ", "))
+}
+
+// ParsePalette reads a theme name, or "RRGGBB,RRGGBB" for foreground and
+// background with optional plane 2 and both colors after them. The planes
+// are in the foreground color if left out.
+func ParsePalette(s string) (Palette, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) == 1 {
+		return LookupTheme(strings.TrimSpace(s))
+	}
+	if len(parts) != 2 && len(parts) != 4 {
+		return Palette{}, fmt.Errorf("palette %q: want foreground,background[,plane2,both]", s)
+	}
+	var cs [4]color.RGBA
+	for i, p := range parts {
+		c, err := parseColor(p)
+		if err != nil {
+			return Palette{}, fmt.Errorf("palette %q: %v", s, err)
+		}
+		cs[i] = c
+	}
+	if len(parts) == 2 {
+		cs[2], cs[3] = cs[0], cs[0]
+	}
+	return Palette{Foreground: cs[0], Background: cs[1], Plane2: cs[2], Both: cs[3]}, nil
+}
+
+// parseColor reads "RRGGBB", optionally with a leading #.
+func parseColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("bad color %q", s)
+	}
+	return rgb(uint32(v)), nil
+}
+
+// LoadPalette reads a palette file: "key = value" lines with # comments,
+// where theme picks a built-in palette to start from and foreground,
+// background, plane2 and both set colors as RRGGBB.
+func LoadPalette(path string) (Palette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Palette{}, err
+	}
+	defer f.Close()
+
+	p := DefaultPalette
+	colors := map[string]*color.RGBA{
+		"foreground": &p.Foreground,
+		"background": &p.Background,
+		"plane2":     &p.Plane2,
+		"both":       &p.Both,
+	}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return Palette{}, fmt.Errorf("%s:%d: expected key = value", path, n)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		// a comment after the value, colors may start with # themselves
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		if key == "theme" {
+			if p, err = LookupTheme(value); err != nil {
+				return Palette{}, fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+			continue
+		}
+		c, ok := colors[key]
+		if !ok {
+			return Palette{}, fmt.Errorf("%s:%d: unknown key %q", path, n, key)
+		}
+		if *c, err = parseColor(value); err != nil {
+			return Palette{}, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Palette{}, err
+	}
+	return p, nil
+}
@@ -0,0 +1,81 @@
+package emulator
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePalette(t *testing.T) {
+	amber := color.RGBA{0xff, 0xb0, 0, 255}
+	p, err := ParsePalette("ffb000, #101010")
+	assert.NoError(t, err)
+	assert.Equal(t, Palette{amber, color.RGBA{0x10, 0x10, 0x10, 255}, amber, amber}, p)
+
+	p, err = ParsePalette("ffb000,101010,800000,ffffff")
+	assert.NoError(t, err)
+	assert.Equal(t, [4]color.RGBA{{0x10, 0x10, 0x10, 255}, amber, {0x80, 0, 0, 255}, {255, 255, 255, 255}}, p.Colors())
+	assert.Equal(t, color.RGBA{0x80, 0, 0, 255}, p.Color(2))
+
+	p, err = ParsePalette("lcd")
+	assert.NoError(t, err)
+	assert.Equal(t, Themes[3].Palette, p)
+
+	for _, s := range []string{"ffb000", "ffb000,10101", "ffb000,zzzzzz", "a,b,c"} {
+		_, err := ParsePalette(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestLoadPalette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "palette")
+	os.WriteFile(path, []byte("# amber with a red second plane\ntheme = amber\nplane2 = #ff0000\n\n"), 0644)
+	p, err := LoadPalette(path)
+	assert.NoError(t, err)
+	want, _ := LookupTheme("amber")
+	want.Plane2 = color.RGBA{255, 0, 0, 255}
+	assert.Equal(t, want, p)
+
+	// the example of the README
+	os.WriteFile(path, []byte("theme = amber        # start from a theme\nbackground = 202020\nplane2 = ff4000\nboth = ffffff\n"), 0644)
+	p, err = LoadPalette(path)
+	assert.NoError(t, err)
+	want.Background = color.RGBA{0x20, 0x20, 0x20, 255}
+	want.Plane2 = color.RGBA{0xff, 0x40, 0, 255}
+	want.Both = color.RGBA{255, 255, 255, 255}
+	assert.Equal(t, want, p)
+
+	os.WriteFile(path, []byte("plane2 = #ff4000 # red\n"), 0644)
+	p, err = LoadPalette(path)
+	assert.NoError(t, err)
+	assert.Equal(t, want.Plane2, p.Plane2)
+
+	for _, s := range []string{"foreground ffffff", "theme = green", "plane3 = ffffff", "both = fff"} {
+		os.WriteFile(path, []byte(s), 0644)
+		_, err := LoadPalette(path)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestPaletteHotkey(t *testing.T) {
+	e, _ := newTestEmulator(toneROM, false, 3, nil, []Event{hotkey(HotkeyPalette)}, []Event{hotkey(HotkeyPalette)})
+	e.Run()
+	assert.Equal(t, Themes[2].Palette, e.Palette())
+
+	// a custom palette comes first in the cycle
+	custom := Palette{Foreground: color.RGBA{1, 2, 3, 255}}
+	e.SetPalette(custom)
+	for range Themes {
+		e.hotkey(HotkeyPalette, 0)
+	}
+	assert.Equal(t, Themes[len(Themes)-1].Palette, e.Palette())
+	e.hotkey(HotkeyPalette, 0)
+	assert.Equal(t, custom, e.Palette())
+
+	e.SetPalette(Themes[1].Palette)
+	e.hotkey(HotkeyPalette, 0)
+	assert.Equal(t, Themes[2].Palette, e.Palette())
+}
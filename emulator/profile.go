@@ -0,0 +1,242 @@
+package emulator
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const maxCallDepth = 17 // stack entries + the executing instruction
+
+// callChain is the executing pc followed by the CALL sites found on the
+// stack, innermost first. entries holds the subroutine each frame belongs to.
+type callChain struct {
+	n       int
+	locs    [maxCallDepth]uint16
+	entries [maxCallDepth]uint16
+}
+
+// Profiler counts executed instructions per address, per opcode class and per call chain.
+type Profiler struct {
+	total     uint64
+	pcs       [4096]uint64
+	classes   [16]uint64
+	chains    map[callChain]uint64
+	mnemonics [4096]string
+}
+
+func NewProfiler() *Profiler {
+	return &Profiler{chains: map[callChain]uint64{}}
+}
+
+func (c *Chip8) callChain() callChain {
+	ch := callChain{n: 1}
+	ch.locs[0] = c.pc
+	for _, ret := range c.callStack() {
+		site := (ret - 2) & AddressMask
+		ch.entries[ch.n-1] = c.opcodeAt(site) & AddressMask
+		ch.locs[ch.n] = site
+		ch.n++
+	}
+	ch.entries[ch.n-1] = ProgramOffset
+	return ch
+}
+
+func (p *Profiler) record(ch callChain, op uint16, mnemonic string) {
+	pc := ch.locs[0]
+	p.total++
+	p.pcs[pc]++
+	p.classes[op>>12]++
+	p.chains[ch]++
+	p.mnemonics[pc] = mnemonic
+}
+
+func subroutineName(addr uint16) string {
+	if addr == ProgramOffset {
+		return "main"
+	}
+	return fmt.Sprintf("sub_%03X", addr)
+}
+
+// WriteReport writes the top hot spots, the opcode classes and the
+// subroutines sorted by executed instructions.
+func (p *Profiler) WriteReport(w io.Writer, top int) {
+	percent := func(n uint64) float64 {
+		if p.total == 0 {
+			return 0
+		}
+		return 100 * float64(n) / float64(p.total)
+	}
+
+	pcs := []int{}
+	for pc, n := range p.pcs {
+		if n > 0 {
+			pcs = append(pcs, pc)
+		}
+	}
+	sort.SliceStable(pcs, func(i, j int) bool { return p.pcs[pcs[i]] > p.pcs[pcs[j]] })
+	if top > 0 && len(pcs) > top {
+		pcs = pcs[:top]
+	}
+	fmt.Fprintf(w, "%d instructions\n\n", p.total)
+	fmt.Fprintf(w, " PC        count      %%  instruction\n")
+	for _, pc := range pcs {
+		fmt.Fprintf(w, "%03X %11d %6.2f  %s\n", pc, p.pcs[pc], percent(p.pcs[pc]), p.mnemonics[pc])
+	}
+
+	fmt.Fprintf(w, "\nclass       count      %%\n")
+	for class, n := range p.classes {
+		if n > 0 {
+			fmt.Fprintf(w, "%XNNN %11d %6.2f\n", class, n, percent(n))
+		}
+	}
+
+	self := map[uint16]uint64{}
+	cum := map[uint16]uint64{}
+	for ch, n := range p.chains {
+		self[ch.entries[0]] += n
+		seen := map[uint16]bool{}
+		for i := 0; i < ch.n; i++ {
+			if !seen[ch.entries[i]] {
+				seen[ch.entries[i]] = true
+				cum[ch.entries[i]] += n
+			}
+		}
+	}
+	subs := []uint16{}
+	for addr := range cum {
+		subs = append(subs, addr)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if cum[subs[i]] != cum[subs[j]] {
+			return cum[subs[i]] > cum[subs[j]]
+		}
+		return subs[i] < subs[j]
+	})
+	fmt.Fprintf(w, "\n       self      %%         cum      %%  subroutine\n")
+	for _, addr := range subs {
+		fmt.Fprintf(w, "%11d %6.2f %11d %6.2f  %s\n", self[addr], percent(self[addr]), cum[addr], percent(cum[addr]), subroutineName(addr))
+	}
+}
+
+// WriteProfile writes a gzipped pprof profile. Every address is a location
+// whose line number is the address itself, inside a function per subroutine.
+func (p *Profiler) WriteProfile(w io.Writer, filename string) error {
+	index := map[string]int{"": 0}
+	table := []string{""}
+	str := func(s string) uint64 {
+		if i, ok := index[s]; ok {
+			return uint64(i)
+		}
+		index[s] = len(table)
+		table = append(table, s)
+		return uint64(len(table) - 1)
+	}
+
+	var prof protoBuffer
+	valueType := func(field int, typ, unit string) {
+		var vt protoBuffer
+		vt.varintField(1, str(typ))
+		vt.varintField(2, str(unit))
+		prof.messageField(field, &vt)
+	}
+	valueType(1, "instructions", "count")
+
+	type location struct{ addr, entry uint16 }
+	locations := map[location]uint64{}
+	functions := map[uint16]bool{}
+	for ch, n := range p.chains {
+		ids := []uint64{}
+		for i := 0; i < ch.n; i++ {
+			l := location{ch.locs[i], ch.entries[i]}
+			if _, ok := locations[l]; !ok {
+				locations[l] = uint64(len(locations) + 1)
+			}
+			ids = append(ids, locations[l])
+			functions[ch.entries[i]] = true
+		}
+		var s protoBuffer
+		s.packedField(1, ids)
+		s.packedField(2, []uint64{n})
+		prof.messageField(2, &s)
+	}
+
+	var m protoBuffer
+	m.varintField(1, 1)
+	m.varintField(3, 0x1000)
+	m.varintField(5, str(filename))
+	m.varintField(7, 1)
+	m.varintField(8, 1)
+	m.varintField(9, 1)
+	prof.messageField(3, &m)
+
+	for l, id := range locations {
+		var line protoBuffer
+		line.varintField(1, uint64(l.entry)+1)
+		line.varintField(2, uint64(l.addr))
+		var loc protoBuffer
+		loc.varintField(1, id)
+		loc.varintField(2, 1)
+		loc.varintField(3, uint64(l.addr))
+		loc.messageField(4, &line)
+		prof.messageField(4, &loc)
+	}
+	for entry := range functions {
+		var f protoBuffer
+		f.varintField(1, uint64(entry)+1)
+		f.varintField(2, str(subroutineName(entry)))
+		f.varintField(3, str(subroutineName(entry)))
+		f.varintField(4, str(filename))
+		f.varintField(5, uint64(entry))
+		prof.messageField(5, &f)
+	}
+
+	valueType(11, "instructions", "count")
+	prof.varintField(12, 1)
+	for _, s := range table {
+		prof.bytesField(6, []byte(s))
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(prof.b); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// protoBuffer encodes the few protobuf wire types needed by the pprof format.
+type protoBuffer struct {
+	b []byte
+}
+
+func (p *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		p.b = append(p.b, byte(x)|0x80)
+		x >>= 7
+	}
+	p.b = append(p.b, byte(x))
+}
+
+func (p *protoBuffer) varintField(field int, x uint64) {
+	p.varint(uint64(field) << 3)
+	p.varint(x)
+}
+
+func (p *protoBuffer) bytesField(field int, b []byte) {
+	p.varint(uint64(field)<<3 | 2)
+	p.varint(uint64(len(b)))
+	p.b = append(p.b, b...)
+}
+
+func (p *protoBuffer) packedField(field int, xs []uint64) {
+	var inner protoBuffer
+	for _, x := range xs {
+		inner.varint(x)
+	}
+	p.bytesField(field, inner.b)
+}
+
+func (p *protoBuffer) messageField(field int, m *protoBuffer) {
+	p.bytesField(field, m.b)
+}
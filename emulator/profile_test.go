@@ -0,0 +1,72 @@
+package emulator
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// main calls sub_206 twice, then loops at 204
+var profileROM = assemble(0x2206, 0x2206, 0x1204, 0x6001, 0x00EE)
+
+func profile(steps int) *Profiler {
+	c := NewChip8(profileROM, QuirkPresets["default"])
+	c.profiler = NewProfiler()
+	for i := 0; i < steps; i++ {
+		c.step()
+	}
+	return c.profiler
+}
+
+func TestProfilerCounts(t *testing.T) {
+	p := profile(10)
+	assert.Equal(t, uint64(10), p.total)
+	assert.Equal(t, uint64(1), p.pcs[0x200])
+	assert.Equal(t, uint64(2), p.pcs[0x206])
+	assert.Equal(t, uint64(4), p.pcs[0x204])
+	assert.Equal(t, uint64(4), p.classes[0x1])
+	assert.Equal(t, uint64(2), p.classes[0x0])
+
+	ch := callChain{n: 2}
+	ch.locs[0], ch.entries[0] = 0x208, 0x206
+	ch.locs[1], ch.entries[1] = 0x202, ProgramOffset
+	assert.Equal(t, uint64(1), p.chains[ch])
+}
+
+func TestProfilerReport(t *testing.T) {
+	var b strings.Builder
+	profile(10).WriteReport(&b, 1)
+	assert.Equal(t, `10 instructions
+
+ PC        count      %  instruction
+204           4  40.00  GOTO 204
+
+class       count      %
+0NNN           2  20.00
+1NNN           4  40.00
+2NNN           2  20.00
+6NNN           2  20.00
+
+       self      %         cum      %  subroutine
+          6  60.00          10 100.00  main
+          4  40.00           4  40.00  sub_206
+`, b.String())
+}
+
+func TestProfilerWriteProfile(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, profile(10).WriteProfile(&b, "test.ch8"))
+
+	r, err := gzip.NewReader(&b)
+	if !assert.NoError(t, err) {
+		return
+	}
+	data, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "sub_206")
+	assert.Contains(t, string(data), "test.ch8")
+}
@@ -0,0 +1,36 @@
+package emulator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Quirks selects between the opcode behaviours that differ across CHIP-8 interpreters.
+type Quirks struct {
+	VFReset bool // 8XY1/8XY2/8XY3 reset VF to 0
+	Memory  bool // FX55/FX65 leave I incremented by X+1
+	Shift   bool // 8XY6/8XYE shift Vx in place instead of loading Vy
+	Jump    bool // BNNN jumps to VX+NNN instead of V0+NNN
+	Wrap    bool // sprites wrap around the display edges instead of clipping
+}
+
+var QuirkPresets = map[string]Quirks{
+	"default": {Shift: true},
+	"vip":     {VFReset: true, Memory: true},
+	"schip":   {Shift: true, Jump: true},
+	"xochip":  {Memory: true, Wrap: true},
+}
+
+func LookupQuirks(name string) (Quirks, error) {
+	q, ok := QuirkPresets[name]
+	if !ok {
+		names := []string{}
+		for n := range QuirkPresets {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return q, fmt.Errorf("unknown quirks preset %q (%s)", name, strings.Join(names, ", "))
+	}
+	return q, nil
+}
@@ -0,0 +1,340 @@
+package emulator
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Recorder captures the display once per frame.
+type Recorder interface {
+	Frame(c *Chip8) error
+	Close() error
+}
+
+// RecordOptions choose how recordings look.
+type RecordOptions struct {
+	Format  string  // gif, y4m or ppm, from the file extension if empty
+	Scale   int     // size of a chip8 pixel, 1 if 0
+	Palette Palette // DefaultPalette if zero, the palette of the emulator for its recordings
+	Beep    bool    // also write the beep track to a WAV file, see beepPath
+}
+
+// RecordFormats are the formats of NewRecorder.
+var RecordFormats = []string{"gif", "y4m", "ppm"}
+
+// CreateRecorder records to path, or to stdout if path is "-".
+func CreateRecorder(path string, o RecordOptions) (Recorder, error) {
+	format := o.Format
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+		if path == "-" {
+			format = "y4m"
+		}
+	}
+	var w io.WriteCloser = os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+	}
+	r, err := NewRecorder(w, format, o.Scale, o.Palette)
+	if err != nil {
+		closeOutput(w)
+		if path != "-" {
+			os.Remove(path)
+		}
+	}
+	return r, err
+}
+
+// NewRecorder writes frames to w in format: an animated GIF that holds each
+// picture until it changes, or a YUV4MPEG2 or binary PPM stream with every
+// frame at 60 fps for external encoders. Close closes w unless it is stdout.
+func NewRecorder(w io.WriteCloser, format string, scale int, p Palette) (Recorder, error) {
+	if scale < 1 {
+		scale = 1
+	}
+	if p == (Palette{}) {
+		p = DefaultPalette
+	}
+	switch format {
+	case "gif":
+		return &gifRecorder{w: w, out: bufio.NewWriter(w), scale: scale, palette: gifPalette(p)}, nil
+	case "y4m":
+		return newStreamRecorder(w, scale, p, true), nil
+	case "ppm":
+		return newStreamRecorder(w, scale, p, false), nil
+	}
+	return nil, fmt.Errorf("unknown recording format %q (want %s)", format, strings.Join(RecordFormats, ", "))
+}
+
+// beepPath is where the beep track of a recording to path goes.
+func beepPath(path string) string {
+	if path == "-" {
+		return "record.wav"
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".wav"
+}
+
+// gifPalette indexes the colors by display value.
+func gifPalette(p Palette) color.Palette {
+	var cp color.Palette
+	for _, c := range p.Colors() {
+		cp = append(cp, c)
+	}
+	return cp
+}
+
+// gifRecorder writes each picture when it is replaced, once its delay is
+// known, so it keeps only one in memory. A picture that does not change is
+// one GIF frame with a longer delay.
+type gifRecorder struct {
+	w       io.WriteCloser
+	out     *bufio.Writer
+	scale   int
+	palette color.Palette
+	pending *image.Paletted // the picture shown since shown, not written yet
+	header  bool
+	last    [Chip8DisplayW * Chip8DisplayH]uint8
+	frames  int // chip8 frames recorded
+	shown   int // time in 1/100s at which the pending picture appeared
+}
+
+// gifMinDelay is the shortest delay browsers honor, in 1/100s. Pictures
+// shown for less are replaced by the next one.
+const gifMinDelay = 2
+
+func (g *gifRecorder) Frame(c *Chip8) error {
+	now := g.frames * 100 / VBlankFrequency
+	g.frames++
+	if g.pending != nil && c.disp == g.last {
+		return nil
+	}
+	g.last = c.disp
+
+	img := image.NewPaletted(image.Rect(0, 0, Chip8DisplayW*g.scale, Chip8DisplayH*g.scale), g.palette)
+	for y := 0; y < Chip8DisplayH*g.scale; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+Chip8DisplayW*g.scale]
+		for x := range row {
+			row[x] = c.disp[y/g.scale*Chip8DisplayW+x/g.scale]
+		}
+	}
+	if g.pending != nil && now-g.shown < gifMinDelay {
+		g.pending = img
+		return nil
+	}
+	if g.pending != nil {
+		if err := g.writeFrame(g.pending, now-g.shown); err != nil {
+			return err
+		}
+	}
+	g.pending, g.shown = img, now
+	return nil
+}
+
+// writeFrame appends img shown for delay 1/100s. image/gif only encodes
+// whole files, so the frame is cut out of a GIF of img alone, which also
+// gives the header the first time.
+func (g *gifRecorder) writeFrame(img *image.Paletted, delay int) error {
+	var b bytes.Buffer
+	err := gif.EncodeAll(&b, &gif.GIF{
+		Image:  []*image.Paletted{img},
+		Delay:  []int{delay},
+		Config: image.Config{ColorModel: g.palette, Width: img.Rect.Dx(), Height: img.Rect.Dy()},
+	})
+	if err != nil {
+		return err
+	}
+	data := b.Bytes()
+	// signature, logical screen descriptor and global color table
+	n := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		n += 3 << ((flags & 7) + 1)
+	}
+	if !g.header {
+		g.header = true
+		g.out.Write(data[:n])
+		// loop forever
+		g.out.WriteString("\x21\xff\x0bNETSCAPE2.0\x03\x01\x00\x00\x00")
+	}
+	// without the trailer
+	_, err = g.out.Write(data[n : len(data)-1])
+	return err
+}
+
+func (g *gifRecorder) Close() error {
+	err := fmt.Errorf("gif: no frames recorded")
+	if g.pending != nil {
+		err = g.writeFrame(g.pending, g.frames*100/VBlankFrequency-g.shown)
+		g.out.WriteByte(0x3b)
+		if ferr := g.out.Flush(); err == nil {
+			err = ferr
+		}
+	}
+	if cerr := closeOutput(g.w); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// streamRecorder writes every frame as it comes, YUV 4:4:4 or RGB.
+type streamRecorder struct {
+	w      io.WriteCloser
+	out    *bufio.Writer
+	scale  int
+	y4m    bool
+	header bool
+	colors [4][3]byte // Y, Cb, Cr or R, G, B by display value
+}
+
+func newStreamRecorder(w io.WriteCloser, scale int, p Palette, y4m bool) *streamRecorder {
+	s := &streamRecorder{w: w, out: bufio.NewWriter(w), scale: scale, y4m: y4m}
+	for i, c := range p.Colors() {
+		if y4m {
+			y, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			s.colors[i] = [3]byte{y, cb, cr}
+		} else {
+			s.colors[i] = [3]byte{c.R, c.G, c.B}
+		}
+	}
+	return s
+}
+
+func (s *streamRecorder) Frame(c *Chip8) error {
+	w, h := Chip8DisplayW*s.scale, Chip8DisplayH*s.scale
+	if s.y4m {
+		if !s.header {
+			fmt.Fprintf(s.out, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C444\n", w, h, VBlankFrequency)
+			s.header = true
+		}
+		s.out.WriteString("FRAME\n")
+		// one plane per component
+		for plane := 0; plane < 3; plane++ {
+			s.pixels(c, func(v uint8) {
+				s.out.WriteByte(s.colors[v&3][plane])
+			})
+		}
+	} else {
+		fmt.Fprintf(s.out, "P6\n%d %d\n255\n", w, h)
+		s.pixels(c, func(v uint8) {
+			s.out.Write(s.colors[v&3][:])
+		})
+	}
+	return s.out.Flush()
+}
+
+// pixels calls put for each pixel of the scaled display, row by row.
+func (s *streamRecorder) pixels(c *Chip8, put func(v uint8)) {
+	for y := 0; y < Chip8DisplayH*s.scale; y++ {
+		for x := 0; x < Chip8DisplayW*s.scale; x++ {
+			put(c.disp[y/s.scale*Chip8DisplayW+x/s.scale])
+		}
+	}
+}
+
+func (s *streamRecorder) Close() error {
+	err := s.out.Flush()
+	if cerr := closeOutput(s.w); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// closeOutput closes w unless it is stdout, which stays open for others.
+func closeOutput(w io.Closer) error {
+	if w == os.Stdout {
+		return nil
+	}
+	return w.Close()
+}
+
+// SetSynth chooses how the beep of recordings sounds.
+func (e *Emulator) SetSynth(o SynthOptions) {
+	e.synth = o
+}
+
+// SetRecording chooses how Record and the record hotkey record.
+func (e *Emulator) SetRecording(o RecordOptions) {
+	e.record = o
+}
+
+// Record records every frame from now on to path, "-" for stdout, until
+// the recording is toggled off or Run returns.
+func (e *Emulator) Record(path string) error {
+	e.stopRecording()
+	o := e.record
+	if o.Palette == (Palette{}) {
+		o.Palette = e.Palette()
+	}
+	r, err := CreateRecorder(path, o)
+	if err != nil {
+		return err
+	}
+	if e.record.Beep {
+		w, err := createWAV(beepPath(path), WAVSampleRate)
+		if err != nil {
+			r.Close()
+			return err
+		}
+		e.recordWAV = w
+	}
+	synth := e.synth
+	synth.SampleRate = WAVSampleRate
+	e.recorder, e.recordBeep = r, NewSynth(synth)
+	return nil
+}
+
+func (e *Emulator) toggleRecording(now time.Time) {
+	if e.recorder != nil {
+		e.stopRecording()
+		fmt.Fprintln(e.log, "recording stopped")
+		return
+	}
+	format := e.record.Format
+	if format == "" {
+		format = "gif"
+	}
+	path := now.Format("chip8-20060102-150405.") + format
+	if err := e.Record(path); err != nil {
+		fmt.Fprintln(e.log, "record:", err)
+		return
+	}
+	fmt.Fprintln(e.log, "recording to", path)
+}
+
+func (e *Emulator) recordFrame() {
+	err := e.recorder.Frame(e.chip8)
+	if err == nil && e.recordWAV != nil {
+		err = e.recordWAV.Write(e.recordBeep.frame(e.focus && e.chip8.Sound()))
+	}
+	if err != nil {
+		fmt.Fprintln(e.log, "record:", err)
+		e.stopRecording()
+	}
+}
+
+func (e *Emulator) stopRecording() {
+	if e.recorder != nil {
+		if err := e.recorder.Close(); err != nil {
+			fmt.Fprintln(e.log, "record:", err)
+		}
+		e.recorder = nil
+	}
+	if e.recordWAV != nil {
+		if err := e.recordWAV.Close(); err != nil {
+			fmt.Fprintln(e.log, "record:", err)
+		}
+		e.recordWAV = nil
+	}
+}
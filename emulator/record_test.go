@@ -0,0 +1,138 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type closeBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closeBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestGIFRecorder(t *testing.T) {
+	var out closeBuffer
+	r, err := NewRecorder(&out, "gif", 2, Palette{})
+	assert.NoError(t, err)
+	c := NewChip8(nil, Quirks{})
+
+	// 30 frames of one picture, a picture replaced within 1/100s, then 30
+	// frames of another
+	for i := 0; i < 30; i++ {
+		assert.NoError(t, r.Frame(c))
+	}
+	c.disp[0] = 1
+	assert.NoError(t, r.Frame(c))
+	c.disp[1] = 1
+	for i := 0; i < 30; i++ {
+		assert.NoError(t, r.Frame(c))
+	}
+	// the first picture is out, only the one shown is kept
+	r.(*gifRecorder).out.Flush()
+	assert.True(t, out.Len() > 0)
+	assert.NoError(t, r.Close())
+	assert.True(t, out.closed)
+
+	g, err := gif.DecodeAll(&out.Buffer)
+	assert.NoError(t, err)
+	assert.Len(t, g.Image, 2)
+	assert.Equal(t, []int{50, 51}, g.Delay)
+	assert.Equal(t, 128, g.Image[1].Bounds().Dx())
+	assert.Equal(t, uint8(1), g.Image[1].ColorIndexAt(3, 0))
+}
+
+func TestStreamRecorders(t *testing.T) {
+	c := NewChip8(nil, Quirks{})
+	c.disp[0] = 1
+
+	var y4m closeBuffer
+	r, err := NewRecorder(&y4m, "y4m", 1, Palette{})
+	assert.NoError(t, err)
+	r.Frame(c)
+	r.Frame(c)
+	r.Close()
+	header := "YUV4MPEG2 W64 H32 F60:1 Ip A1:1 C444\n"
+	frame := len("FRAME\n") + 3*64*32
+	assert.True(t, strings.HasPrefix(y4m.String(), header+"FRAME\n"))
+	assert.Equal(t, len(header)+2*frame, y4m.Len())
+
+	var ppm closeBuffer
+	r, err = NewRecorder(&ppm, "ppm", 2, Palette{})
+	assert.NoError(t, err)
+	r.Frame(c)
+	r.Close()
+	assert.Equal(t, "P6\n128 64\n255\n\x00\xff\x00\x00\xff\x00\x00\x00\x00", ppm.String()[:23])
+	assert.Equal(t, 14+3*128*64, ppm.Len())
+
+	_, err = NewRecorder(&ppm, "mp4", 1, Palette{})
+	assert.Error(t, err)
+}
+
+func TestRecordWithBeep(t *testing.T) {
+	dir, err := os.MkdirTemp("", "record")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	e, _ := newTestEmulator(toneROM, false, 10)
+	e.SetRecording(RecordOptions{Beep: true})
+	assert.NoError(t, e.Record(filepath.Join(dir, "clip.gif")))
+	e.Run()
+
+	f, err := os.Open(filepath.Join(dir, "clip.gif"))
+	if assert.NoError(t, err) {
+		g, err := gif.DecodeAll(f)
+		f.Close()
+		assert.NoError(t, err)
+		assert.Len(t, g.Image, 1)
+	}
+
+	wav, err := os.ReadFile(filepath.Join(dir, "clip.wav"))
+	assert.NoError(t, err)
+	samples := 10 * WAVSampleRate / VBlankFrequency
+	assert.Equal(t, 44+2*samples, len(wav))
+	assert.Equal(t, "RIFF", string(wav[:4]))
+	assert.Equal(t, uint32(2*samples), binary.LittleEndian.Uint32(wav[40:]))
+	// the tone of toneROM lasts 3 frames
+	pcm := wav[44:]
+	assert.NotEqual(t, make([]byte, 100), pcm[:100])
+	assert.Equal(t, make([]byte, 100), pcm[len(pcm)-100:])
+}
+
+func TestRecordToStdout(t *testing.T) {
+	dir := t.TempDir()
+	out, err := os.Create(filepath.Join(dir, "stdout"))
+	assert.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+
+	// a stack fault and a screenshot between the frames
+	e := NewEmulator(assemble(0x00EE, 0x1202), false, QuirkPresets["default"], NullFrontend())
+	var log strings.Builder
+	e.log, e.debugger.out = &log, &log
+	e.SetMaxFrames(3)
+	e.ScreenshotAt(2, filepath.Join(dir, "shot.png"))
+	assert.NoError(t, e.Record("-"))
+	e.Run()
+	os.Stdout = stdout
+	out.Close()
+
+	y4m, err := os.ReadFile(out.Name())
+	assert.NoError(t, err)
+	header := "YUV4MPEG2 W64 H32 F60:1 Ip A1:1 C444\n"
+	assert.Equal(t, len(header)+3*(len("FRAME\n")+3*64*32), len(y4m))
+	assert.Contains(t, log.String(), "RET at 200 with empty stack")
+	assert.Contains(t, log.String(), "screenshot saved to")
+}
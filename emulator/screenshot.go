@@ -0,0 +1,128 @@
+package emulator
+
+import (
+	"bytes"
+	_ "embed"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"time"
+)
+
+// fontPNG is the font of the debug panels, white glyphs on black,
+// FontPerW per row from ' '.
+//
+//go:embed image/font.png
+var fontPNG []byte
+
+// ScreenshotOptions choose how a screenshot looks.
+type ScreenshotOptions struct {
+	Scale   int     // size of a chip8 pixel, 1 if 0
+	Palette Palette // DefaultPalette if zero, the palette of the emulator for its screenshots
+	Panel   bool    // the registers and the instruction at pc below the display, not the other debug panels
+}
+
+// Screenshot draws the display. It needs no frontend.
+func (c *Chip8) Screenshot(o ScreenshotOptions) (image.Image, error) {
+	return screenshot(c, o, nil, false)
+}
+
+// WriteScreenshot writes a Screenshot as PNG.
+func (c *Chip8) WriteScreenshot(w io.Writer, o ScreenshotOptions) error {
+	img, err := c.Screenshot(o)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+func screenshot(c *Chip8, o ScreenshotOptions, sym *Symbols, stepMode bool) (image.Image, error) {
+	scale := o.Scale
+	if scale < 1 {
+		scale = 1
+	}
+	if o.Palette == (Palette{}) {
+		o.Palette = DefaultPalette
+	}
+	w, h := Chip8DisplayW*scale, Chip8DisplayH*scale
+
+	var lines []string
+	if o.Panel {
+		lines = registerLines(c, sym, stepMode)[:3]
+		for _, l := range lines {
+			if len(l)*FontSize > w {
+				w = len(l) * FontSize
+			}
+		}
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h+len(lines)*FontSize))
+	draw.Draw(img, img.Bounds(), &image.Uniform{o.Palette.Background}, image.Point{}, draw.Src)
+	for y := 0; y < Chip8DisplayH; y++ {
+		for x := 0; x < Chip8DisplayW; x++ {
+			if v := c.disp[y*Chip8DisplayW+x]; v != 0 {
+				draw.Draw(img, image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale), &image.Uniform{o.Palette.Color(v)}, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	if len(lines) > 0 {
+		font, err := loadFont()
+		if err != nil {
+			return nil, err
+		}
+		for i, l := range lines {
+			drawText(img, font, &image.Uniform{o.Palette.Foreground}, l, 0, h+i*FontSize)
+		}
+	}
+	return img, nil
+}
+
+func loadFont() (image.Image, error) {
+	return png.Decode(bytes.NewReader(fontPNG))
+}
+
+// fontMask turns the white on black glyphs into an alpha mask.
+type fontMask struct{ image.Image }
+
+func (fontMask) ColorModel() color.Model { return color.AlphaModel }
+
+func (m fontMask) At(x, y int) color.Color {
+	r, _, _, _ := m.Image.At(x, y).RGBA()
+	return color.Alpha{uint8(r >> 8)}
+}
+
+// drawText draws s with the font of the SDL window, FontSize per character.
+func drawText(dst draw.Image, font image.Image, c image.Image, s string, x, y int) {
+	mask := fontMask{font}
+	for i, v := range []byte(s) {
+		v -= byte(' ')
+		glyph := image.Pt(FontSize*(int(v)%FontPerW), FontSize*(int(v)/FontPerW))
+		r := image.Rect(x+i*FontSize, y, x+(i+1)*FontSize, y+FontSize)
+		draw.DrawMask(dst, r, c, image.Point{}, mask, glyph, draw.Over)
+	}
+}
+
+// screenshotName is a file name for a screenshot taken now.
+func screenshotName(now time.Time) string {
+	return now.Format("chip8-20060102-150405.000.png")
+}
+
+// saveScreenshot writes the display of m to path as PNG.
+func (m *machine) saveScreenshot(path string, o ScreenshotOptions) error {
+	img, err := screenshot(m.chip8, o, m.symbols, m.stepMode)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
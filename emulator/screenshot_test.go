@@ -0,0 +1,64 @@
+package emulator
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScreenshot(t *testing.T) {
+	c := NewChip8(nil, Quirks{})
+	c.disp[Chip8DisplayW+2] = 1 // (2,1)
+	amber := Palette{Foreground: color.RGBA{255, 176, 0, 255}, Background: color.RGBA{16, 16, 16, 255}}
+
+	var b bytes.Buffer
+	assert.NoError(t, c.WriteScreenshot(&b, ScreenshotOptions{Scale: 3, Palette: amber}))
+	img, err := png.Decode(&b)
+	assert.NoError(t, err)
+	assert.Equal(t, 64*3, img.Bounds().Dx())
+	assert.Equal(t, 32*3, img.Bounds().Dy())
+	assert.Equal(t, amber.Foreground, color.RGBAModel.Convert(img.At(2*3, 1*3)))
+	assert.Equal(t, amber.Foreground, color.RGBAModel.Convert(img.At(2*3+2, 1*3+2)))
+	assert.Equal(t, amber.Background, color.RGBAModel.Convert(img.At(2*3+3, 1*3)))
+
+	img, err = c.Screenshot(ScreenshotOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultPalette.Foreground, color.RGBAModel.Convert(img.At(2, 1)))
+}
+
+func TestScreenshotPanel(t *testing.T) {
+	c := NewChip8(nil, Quirks{})
+	img, err := c.Screenshot(ScreenshotOptions{Scale: 10, Panel: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 32*10+3*FontSize, img.Bounds().Dy())
+	lit := 0
+	for y := 32 * 10; y < img.Bounds().Dy(); y++ {
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			if color.RGBAModel.Convert(img.At(x, y)) == DefaultPalette.Foreground {
+				lit++
+			}
+		}
+	}
+	assert.True(t, lit > 0)
+}
+
+func TestScreenshotAt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shot.png")
+	e, _ := newTestEmulator(toneROM, false, 3)
+	e.SetScreenshot(ScreenshotOptions{Scale: 2})
+	e.ScreenshotAt(2, path)
+	e.Run()
+
+	f, err := os.Open(path)
+	if assert.NoError(t, err) {
+		defer f.Close()
+		img, err := png.Decode(f)
+		assert.NoError(t, err)
+		assert.Equal(t, 128, img.Bounds().Dx())
+	}
+}
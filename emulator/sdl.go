@@ -0,0 +1,557 @@
+//go:build !nosdl && !js
+// +build !nosdl,!js
+
+package emulator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"os"
+
+	"github.com/veandco/go-sdl2/img"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// left column of the debug area, switched with TAB
+const (
+	PanelHistory = iota
+	PanelCallStack
+	PanelMemory
+	PanelDisasm
+	PanelSprites
+	PanelHeatmap
+	PanelNum
+)
+
+// sdlFrontend draws the chip8 display and the debug panels in a window,
+// plays the buzzer and reads the keyboard.
+type sdlFrontend struct {
+	renderer *sdl.Renderer
+	audio    sdl.AudioDeviceID
+	synth    *Synth
+	queue    int // samples to keep queued on audio
+	font     *sdl.Texture
+	palette  Palette // of the frame being drawn
+
+	panel        int
+	memview      *memoryView
+	disasmCursor uint16
+	disasmFollow bool
+	spriteview   *spriteView
+	sprites      []SpriteRef
+}
+
+var scanCode2Key = map[int]byte{
+	sdl.SCANCODE_4: 0x1,
+	sdl.SCANCODE_5: 0x2,
+	sdl.SCANCODE_6: 0x3,
+	sdl.SCANCODE_7: 0xc,
+	sdl.SCANCODE_R: 0x4,
+	sdl.SCANCODE_T: 0x5,
+	sdl.SCANCODE_Y: 0x6,
+	sdl.SCANCODE_U: 0xd,
+	sdl.SCANCODE_F: 0x7,
+	sdl.SCANCODE_G: 0x8,
+	sdl.SCANCODE_H: 0x9,
+	sdl.SCANCODE_J: 0xe,
+	sdl.SCANCODE_V: 0xa,
+	sdl.SCANCODE_B: 0x0,
+	sdl.SCANCODE_N: 0xb,
+	sdl.SCANCODE_M: 0xf,
+}
+
+var scanCode2Hex = map[int]byte{
+	sdl.SCANCODE_0: 0x0,
+	sdl.SCANCODE_1: 0x1,
+	sdl.SCANCODE_2: 0x2,
+	sdl.SCANCODE_3: 0x3,
+	sdl.SCANCODE_4: 0x4,
+	sdl.SCANCODE_5: 0x5,
+	sdl.SCANCODE_6: 0x6,
+	sdl.SCANCODE_7: 0x7,
+	sdl.SCANCODE_8: 0x8,
+	sdl.SCANCODE_9: 0x9,
+	sdl.SCANCODE_A: 0xa,
+	sdl.SCANCODE_B: 0xb,
+	sdl.SCANCODE_C: 0xc,
+	sdl.SCANCODE_D: 0xd,
+	sdl.SCANCODE_E: 0xe,
+	sdl.SCANCODE_F: 0xf,
+}
+
+func checkError(s string, e error) {
+	if e != nil {
+		log.Fatalf(s, e)
+	}
+}
+
+func initRenderer() *sdl.Renderer {
+	window, err := sdl.CreateWindow("Chip-8 Emulator", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, WindowW, WindowH, sdl.WINDOW_SHOWN)
+	checkError("CreateWindow", err)
+
+	renderer, err := sdl.CreateRenderer(window, -1, sdl.RENDERER_PRESENTVSYNC)
+	checkError("CreateRenderer", err)
+
+	// workaround for https://bugzilla.libsdl.org/show_bug.cgi?id=4272
+	// 	or update sdl2 to 2.0.9
+	window.Hide()
+	sdl.PumpEvents()
+	window.Show()
+
+	return renderer
+}
+
+// initAudio opens a device that plays the samples of a synth at its rate.
+// The format stays float mono, SDL converts if the hardware differs.
+func initAudio(o SynthOptions) (sdl.AudioDeviceID, *Synth) {
+	want := &sdl.AudioSpec{
+		Freq:     int32(o.SampleRate),
+		Format:   sdl.AUDIO_F32LSB,
+		Channels: 1,
+		Samples:  AudioSamples,
+	}
+	have := &sdl.AudioSpec{}
+	audio, err := sdl.OpenAudioDevice("", false, want, have, sdl.AUDIO_ALLOW_FREQUENCY_CHANGE)
+	checkError("OpenAudioDevice", err)
+	o.SampleRate = int(have.Freq)
+
+	sdl.PauseAudioDevice(audio, false)
+	return audio, NewSynth(o)
+}
+
+func initFont(r *sdl.Renderer) *sdl.Texture {
+	rw, err := sdl.RWFromMem(fontPNG)
+	checkError("RWFromMem", err)
+	surface, err := img.LoadRW(rw, true)
+	checkError("LoadRW", err)
+	defer surface.Free()
+
+	texture, err := r.CreateTextureFromSurface(surface)
+	checkError("CreateTextureFromSurface", err)
+
+	texture.SetBlendMode(sdl.BLENDMODE_ADD)
+
+	return texture
+}
+
+// NewSDLFrontend opens the window and an audio device for the buzzer of o.
+func NewSDLFrontend(o SynthOptions) Frontend {
+	err := sdl.Init(sdl.INIT_EVERYTHING)
+	checkError("sdl.Init", err)
+
+	renderer := initRenderer()
+	f := &sdlFrontend{renderer: renderer, font: initFont(renderer), memview: newMemoryView(), disasmFollow: true}
+	f.audio, f.synth = initAudio(o)
+	f.queue = int(o.Latency.Seconds() * float64(f.synth.SampleRate))
+	// less than the device takes at once plus a frame's worth underruns
+	if least := AudioSamples + f.synth.SampleRate/VBlankFrequency; f.queue < least {
+		f.queue = least
+	}
+	f.spriteview = newSpriteView()
+	return Frontend{Video: f, Audio: f, Input: f}
+}
+
+func (f *sdlFrontend) Present(e *Emulator) {
+	f.palette = e.Palette()
+	f.setDrawColor(f.palette.Background)
+	f.renderer.Clear()
+
+	// chip8 display
+	for y := int32(0); y < Chip8DisplayH; y++ {
+		for x := int32(0); x < Chip8DisplayW; x++ {
+			if v := e.chip8.disp[y*Chip8DisplayW+x]; v != 0 {
+				f.setDrawColor(f.palette.Color(v))
+				f.renderer.FillRect(&sdl.Rect{X: x * DisplayScale, Y: y * DisplayScale, W: DisplayScale, H: DisplayScale})
+			}
+		}
+	}
+
+	f.drawDebugInfo(e)
+
+	f.renderer.Present()
+}
+
+var scanCode2Hotkey = map[int]Hotkey{
+	sdl.SCANCODE_SPACE:  HotkeyStep,
+	sdl.SCANCODE_RETURN: HotkeyResume,
+	sdl.SCANCODE_Z:      HotkeyReset,
+	sdl.SCANCODE_F10:    HotkeyStepOver,
+	sdl.SCANCODE_F11:    HotkeyStepOut,
+	sdl.SCANCODE_F6:     HotkeyRunFrame,
+	sdl.SCANCODE_F4:     HotkeyRunTo,
+	sdl.SCANCODE_F9:     HotkeyToggleBreakpoint,
+	sdl.SCANCODE_F12:    HotkeyScreenshot,
+	sdl.SCANCODE_F7:     HotkeyRecord,
+	sdl.SCANCODE_F8:     HotkeyPalette,
+}
+
+func (f *sdlFrontend) Poll(e *Emulator) []Event {
+	var events []Event
+	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+		switch ev := event.(type) {
+		case *sdl.QuitEvent:
+			events = append(events, Event{Kind: EventQuit})
+		case *sdl.KeyboardEvent:
+			code := int(ev.Keysym.Scancode)
+			switch ev.Type {
+			case sdl.KEYDOWN:
+				if f.panel == PanelMemory && f.memoryKey(e, code) {
+					continue
+				}
+				if f.panel == PanelSprites && f.spriteKey(e, code) {
+					continue
+				}
+				if f.panel == PanelDisasm && f.disasmKey(e, code) {
+					continue
+				}
+				if i, ok := scanCode2Key[code]; ok {
+					events = append(events, Event{Kind: EventKeyDown, Key: i})
+				} else if h, ok := scanCode2Hotkey[code]; ok {
+					events = append(events, Event{Kind: EventHotkey, Hotkey: h, Addr: f.cursor(e)})
+				} else if code == sdl.SCANCODE_TAB {
+					f.panel = (f.panel + 1) % PanelNum
+				}
+			case sdl.KEYUP:
+				if i, ok := scanCode2Key[code]; ok {
+					events = append(events, Event{Kind: EventKeyUp, Key: i})
+				}
+			}
+		case *sdl.WindowEvent:
+			switch ev.Event {
+			case sdl.WINDOWEVENT_FOCUS_LOST:
+				events = append(events, Event{Kind: EventFocusLost})
+			case sdl.WINDOWEVENT_FOCUS_GAINED:
+				events = append(events, Event{Kind: EventFocusGained})
+			}
+		}
+	}
+	return events
+}
+
+// Tone tops the device queue up to f.queue samples, silence included, so
+// the device never runs dry between frames and a tone starts at most
+// f.queue samples late however late the frames come. An SDL audio callback
+// would have to be a C function, queueing keeps this in Go.
+func (f *sdlFrontend) Tone(on bool) {
+	f.synth.SetTone(on)
+	n := f.queue - int(sdl.GetQueuedAudioSize(f.audio))/4
+	if n <= 0 {
+		return
+	}
+	pcm := make([]float32, n)
+	f.synth.Read(pcm)
+	samples := make([]byte, 4*n)
+	for i, v := range pcm {
+		binary.LittleEndian.PutUint32(samples[4*i:], math.Float32bits(v))
+	}
+	if err := sdl.QueueAudio(f.audio, samples); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+func (f *sdlFrontend) drawDebugInfo(e *Emulator) {
+	f.renderer.SetDrawColor(32, 32, 32, 255)
+	f.renderer.FillRect(&sdl.Rect{X: 0, Y: EmulatorH, W: EmulatorW, H: InformationH})
+
+	switch f.panel {
+	case PanelHistory:
+		f.drawHistory(e)
+	case PanelCallStack:
+		f.drawCallStack(e)
+	case PanelMemory:
+		f.drawMemory(e)
+	case PanelDisasm:
+		f.drawDisasm(e)
+	case PanelSprites:
+		f.drawSprites(e)
+	case PanelHeatmap:
+		f.drawHeatmap(e)
+	}
+
+	// draw v registers
+	offsetX := EmulatorW/2 + 48
+	for i, v := range e.chip8.v {
+		f.drawRegister(e, i, fmt.Sprintf("V%X = ", i), fmt.Sprintf("%02X", v), offsetX, EmulatorH+i*FontSize)
+	}
+
+	// draw other registers
+	offsetX = EmulatorW - FontSize*9
+	f.drawRegister(e, TargetDT, "DT = ", fmt.Sprintf("%02X", e.chip8.dt), offsetX, EmulatorH+FontSize*0)
+	f.drawRegister(e, TargetST, "ST = ", fmt.Sprintf("%02X", e.chip8.st), offsetX, EmulatorH+FontSize*1)
+	f.drawText(fmt.Sprintf("SP = %02X", e.chip8.sp), offsetX, EmulatorH+FontSize*2)
+	f.drawRegister(e, TargetI, " I = ", fmt.Sprintf("%04X", e.chip8.i), offsetX, EmulatorH+FontSize*3)
+
+	// draw key inputs
+	keys := e.chip8.keys
+	f.drawText(fmt.Sprintf("KEYS %d%d%d%d", keys[0x01], keys[0x02], keys[0x03], keys[0x0c]), offsetX, EmulatorH+FontSize*5)
+	f.drawText(fmt.Sprintf("     %d%d%d%d", keys[0x04], keys[0x05], keys[0x06], keys[0x0d]), offsetX, EmulatorH+FontSize*6)
+	f.drawText(fmt.Sprintf("     %d%d%d%d", keys[0x07], keys[0x08], keys[0x09], keys[0x0e]), offsetX, EmulatorH+FontSize*7)
+	f.drawText(fmt.Sprintf("     %d%d%d%d", keys[0x0a], keys[0x00], keys[0x0b], keys[0x0f]), offsetX, EmulatorH+FontSize*8)
+}
+
+func (f *sdlFrontend) drawHistory(e *Emulator) {
+	for i := 0; i < OpHistoryNum; i++ {
+		h := e.chip8.ophistory[(e.chip8.ophistoryIndex+i)%OpHistoryNum]
+		if h.valid {
+			f.drawText(clipText(formatInstruction(h.pc, h.op, e.symbols, e.chip8.quirks), HistoryChars), 0, EmulatorH+i*FontSize)
+		}
+	}
+}
+
+func (f *sdlFrontend) drawCallStack(e *Emulator) {
+	f.drawText(fmt.Sprintf("STACK %d/%d", e.chip8.depth, len(e.chip8.stack)), 0, EmulatorH)
+	f.drawText(clipText(fmt.Sprintf("PC  %03X %s", e.chip8.pc, e.symbols.Lookup(e.chip8.pc)), HistoryChars), 0, EmulatorH+FontSize)
+
+	rows := PanelRows - 2
+	for i, ret := range e.chip8.callStack() {
+		if i == rows-1 && int(e.chip8.depth) > rows {
+			f.drawText(fmt.Sprintf("... %d more", int(e.chip8.depth)-i), 0, EmulatorH+(i+2)*FontSize)
+			break
+		}
+		f.drawText(clipText(fmt.Sprintf("RET %03X %s", ret, e.symbols.Lookup(ret)), HistoryChars), 0, EmulatorH+(i+2)*FontSize)
+	}
+}
+
+func (f *sdlFrontend) drawMemory(e *Emulator) {
+	m := f.memview
+	m.update(e.chip8)
+	f.drawText(m.header(), 0, EmulatorH)
+
+	for r := 0; r < MemoryRows; r++ {
+		addr := m.top + uint16(r*MemoryBytesPerRow)
+		y := EmulatorH + (r+1)*FontSize
+		for j := 0; j < MemoryBytesPerRow; j++ {
+			a := (addr + uint16(j)) & AddressMask
+			if a == m.cursor && m.target == TargetMemory {
+				f.drawHighlight(memoryColumn(j)*FontSize, y, 2, 0, 0, 160)
+			} else if e.chip8.recentlyWritten(a) {
+				f.drawHighlight(memoryColumn(j)*FontSize, y, 2, 160, 0, 0)
+			}
+		}
+		f.drawText(m.row(e.chip8, addr), 0, y)
+	}
+}
+
+// cursor is the disassembly line F4 and F9 act on, pc unless moved.
+func (f *sdlFrontend) cursor(e *Emulator) uint16 {
+	if f.disasmFollow {
+		f.disasmCursor = e.chip8.pc
+	}
+	return f.disasmCursor
+}
+
+func (f *sdlFrontend) drawDisasm(e *Emulator) {
+	cursor := f.cursor(e)
+	for i, l := range e.chip8.disasmWindow(cursor, PanelRows, e.symbols, e.debugger.breakpoints) {
+		y := EmulatorH + i*FontSize
+		if l.current {
+			f.drawHighlight(0, y, HistoryChars, 0, 0, 160)
+		} else if l.addr == cursor {
+			f.drawHighlight(0, y, HistoryChars, 64, 64, 64)
+		} else if l.target {
+			f.drawHighlight(0, y, HistoryChars, 0, 96, 0)
+		}
+		if l.breakpoint {
+			f.drawHighlight(0, y, 1, 160, 0, 0)
+		}
+		f.drawText(clipText(l.String(), HistoryChars), 0, y)
+	}
+}
+
+// disasmKey handles the keys of the disassembly panel and reports whether
+// the key was used.
+func (f *sdlFrontend) disasmKey(e *Emulator, code int) bool {
+	switch code {
+	case sdl.SCANCODE_UP:
+		f.disasmCursor = (f.cursor(e) - 2) & AddressMask
+		f.disasmFollow = false
+	case sdl.SCANCODE_DOWN:
+		f.disasmCursor = (f.cursor(e) + 2) & AddressMask
+		f.disasmFollow = false
+	case sdl.SCANCODE_P:
+		f.disasmFollow = true
+	default:
+		return false
+	}
+	return true
+}
+
+// drawSprites shows the bytes at the sprite address as an 8xN and a 16x16
+// sprite, the built-in font, the sprites found in the rom and those guessed
+// from its data.
+func (f *sdlFrontend) drawSprites(e *Emulator) {
+	v := f.spriteview
+	v.update(e.chip8)
+	h := v.spriteHeight(e.chip8)
+	follow := ""
+	if v.follow {
+		follow = " @I"
+	}
+	f.drawText(fmt.Sprintf("SPR %03X 8x%d%s", v.addr, h, follow), 0, EmulatorH)
+
+	y := EmulatorH + FontSize
+	f.drawPixels(spritePixels(e.chip8.mem[:], v.addr, h), 0, y, 8)
+	f.drawPixels(spritePixels(e.chip8.mem[:], v.addr, 0), 80, y, 4)
+
+	y += 128
+	for i, r := range fontRefs() {
+		f.drawPixels(spritePixels(e.chip8.mem[:], r.Addr, r.Height), i*20, y, 2)
+	}
+
+	y += 16
+	if f.sprites == nil {
+		f.sprites = FindSprites(e.rom)
+		f.sprites = append(f.sprites, ScanSprites(e.rom, f.sprites)...)
+	}
+	for i, r := range f.sprites {
+		x := i % 10 * 36
+		row := y + i/10*36
+		if row+32 > WindowH {
+			break
+		}
+		f.drawPixels(spritePixels(e.chip8.mem[:], r.Addr, r.Height), x, row, 2)
+	}
+}
+
+// drawHeatmap draws one cell per address, writes red, fetches green and
+// reads blue.
+func (f *sdlFrontend) drawHeatmap(e *Emulator) {
+	const scale = InformationH / HeatmapW
+	for a := uint16(0); a < 4096; a++ {
+		c := e.heatmap.recentColor(a)
+		f.renderer.SetDrawColor(c.R, c.G, c.B, 255)
+		f.renderer.FillRect(&sdl.Rect{X: int32(a) % HeatmapW * scale, Y: EmulatorH + int32(a)/HeatmapW*scale, W: scale, H: scale})
+	}
+
+	offsetX := HeatmapW*scale + FontSize/2
+	f.drawText("HEAT", offsetX, EmulatorH)
+	f.drawText("W RED", offsetX, EmulatorH+FontSize*2)
+	f.drawText("X GRN", offsetX, EmulatorH+FontSize*3)
+	f.drawText("R BLU", offsetX, EmulatorH+FontSize*4)
+}
+
+func (f *sdlFrontend) setDrawColor(c color.RGBA) {
+	f.renderer.SetDrawColor(c.R, c.G, c.B, c.A)
+}
+
+func (f *sdlFrontend) drawPixels(px [][]bool, x, y, scale int) {
+	f.setDrawColor(f.palette.Background)
+	f.renderer.FillRect(&sdl.Rect{X: int32(x), Y: int32(y), W: int32(len(px[0]) * scale), H: int32(len(px) * scale)})
+	f.setDrawColor(f.palette.Foreground)
+	for iy, row := range px {
+		for ix, on := range row {
+			if on {
+				f.renderer.FillRect(&sdl.Rect{X: int32(x + ix*scale), Y: int32(y + iy*scale), W: int32(scale), H: int32(scale)})
+			}
+		}
+	}
+}
+
+// spriteKey handles the keys of the sprite panel and reports whether the key
+// was used.
+func (f *sdlFrontend) spriteKey(e *Emulator, code int) bool {
+	v := f.spriteview
+	switch code {
+	case sdl.SCANCODE_LEFT:
+		v.move(-1)
+	case sdl.SCANCODE_RIGHT:
+		v.move(1)
+	case sdl.SCANCODE_UP:
+		v.move(-v.spriteHeight(e.chip8))
+	case sdl.SCANCODE_DOWN:
+		v.move(v.spriteHeight(e.chip8))
+	case sdl.SCANCODE_PAGEUP:
+		v.resize(e.chip8, -1)
+	case sdl.SCANCODE_PAGEDOWN:
+		v.resize(e.chip8, 1)
+	case sdl.SCANCODE_I:
+		v.follow = true
+	default:
+		return false
+	}
+	return true
+}
+
+// memoryKey handles the keys of the memory panel and reports whether the key
+// was used. While a value is being edited every key goes to the editor.
+func (f *sdlFrontend) memoryKey(e *Emulator, code int) bool {
+	m := f.memview
+	if m.editing {
+		if d, ok := scanCode2Hex[code]; ok {
+			m.typeDigit(d)
+		} else if code == sdl.SCANCODE_BACKSPACE {
+			m.backspace()
+		} else if code == sdl.SCANCODE_RETURN {
+			m.commitEdit(e.chip8)
+		} else if code == sdl.SCANCODE_ESCAPE {
+			m.cancelEdit()
+		}
+		return true
+	}
+
+	switch code {
+	case sdl.SCANCODE_UP:
+		m.moveRows(-1)
+	case sdl.SCANCODE_DOWN:
+		m.moveRows(1)
+	case sdl.SCANCODE_LEFT:
+		m.move(-1)
+	case sdl.SCANCODE_RIGHT:
+		m.move(1)
+	case sdl.SCANCODE_PAGEUP:
+		m.moveRows(-MemoryRows)
+	case sdl.SCANCODE_PAGEDOWN:
+		m.moveRows(MemoryRows)
+	case sdl.SCANCODE_I:
+		m.setFollow(FollowI)
+	case sdl.SCANCODE_P:
+		m.setFollow(FollowPC)
+	case sdl.SCANCODE_S:
+		m.toggleTarget()
+	case sdl.SCANCODE_E:
+		// editing is only allowed while paused
+		if !e.stepMode {
+			return false
+		}
+		m.beginEdit()
+	default:
+		return false
+	}
+	return true
+}
+
+// drawRegister draws label and value, highlighting the register selected for
+// editing in the memory panel.
+func (f *sdlFrontend) drawRegister(e *Emulator, target int, label, value string, x, y int) {
+	if f.panel == PanelMemory && f.memview.target == target {
+		value = f.memview.field(target, value)
+		f.drawHighlight(x+len(label)*FontSize, y, len(value), 0, 0, 160)
+	}
+	f.drawText(label+value, x, y)
+}
+
+func (f *sdlFrontend) drawHighlight(x, y, chars int, r, g, b uint8) {
+	f.renderer.SetDrawColor(r, g, b, 255)
+	f.renderer.FillRect(&sdl.Rect{X: int32(x), Y: int32(y), W: int32(chars * FontSize), H: FontSize})
+}
+
+func clipText(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func (f *sdlFrontend) drawText(s string, x, y int) {
+	for i, v := range []byte(s) {
+		v -= byte(' ')
+		fx := FontSize * (int32(v) % FontPerW)
+		fy := FontSize * (int32(v) / FontPerW)
+		f.renderer.Copy(f.font,
+			&sdl.Rect{X: fx, Y: fy, W: FontSize, H: FontSize},
+			&sdl.Rect{X: int32(x + i*FontSize), Y: int32(y), W: FontSize, H: FontSize})
+	}
+}
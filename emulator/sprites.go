@@ -0,0 +1,228 @@
+package emulator
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"sort"
+)
+
+// SpriteRef is sprite data found in a rom. Height 0 is a 16x16 sprite
+// (SCHIP DXY0), otherwise the sprite is 8 pixels wide.
+type SpriteRef struct {
+	Addr   uint16
+	Height int
+}
+
+// FindSprites scans the reachable code of rom for DXYN instructions and
+// returns the address I was last loaded with (ANNN) before each of them.
+func FindSprites(rom []byte) []SpriteRef {
+	c := NewChip8(rom, Quirks{})
+	code := reachable(c.mem[:])
+
+	heights := map[uint16]int{}
+	i, loaded := uint16(0), false
+	for addr := 0; addr < len(code); addr++ {
+		if !code[addr] {
+			continue
+		}
+		op := c.opcodeAt(uint16(addr))
+		switch {
+		case op&0xF000 == 0xA000:
+			i, loaded = op&0x0FFF, true
+		case op&0xF000 == 0xD000 && loaded:
+			n := int(op & 0x000F)
+			// keep the largest use, 16x16 above all
+			if h, ok := heights[i]; !ok || n == 0 || (h != 0 && n > h) {
+				heights[i] = n
+			}
+		}
+	}
+
+	refs := []SpriteRef{}
+	for addr, h := range heights {
+		refs = append(refs, SpriteRef{addr, h})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Addr < refs[j].Addr })
+	return refs
+}
+
+// ScanSprites guesses sprites in the data of rom that FindSprites misses,
+// e.g. sprite tables indexed at run time. Runs of bytes outside the reachable
+// code and the known sprites, split at two zero bytes in a row, are taken as
+// 8xN sprites; runs longer than 15 bytes are cut into sprites as tall as the
+// ones the rom draws most, or 8.
+func ScanSprites(rom []byte, known []SpriteRef) []SpriteRef {
+	c := NewChip8(rom, Quirks{})
+	code := reachable(c.mem[:])
+	end := ProgramOffset + len(rom)
+	if end > len(c.mem) {
+		end = len(c.mem)
+	}
+
+	used := code
+	tall := map[int]int{}
+	for _, r := range known {
+		h := r.Height
+		if h == 0 {
+			h = 32
+		}
+		for a := 0; a < h; a++ {
+			used[(int(r.Addr)+a)&AddressMask] = true
+		}
+		tall[r.Height]++
+	}
+	// code is marked by its first byte
+	for a := range code {
+		if code[a] {
+			used[(a+1)&AddressMask] = true
+		}
+	}
+	height := 8
+	for h, n := range tall {
+		if h > 0 && (n > tall[height] || n == tall[height] && h > height) {
+			height = h
+		}
+	}
+
+	refs := []SpriteRef{}
+	add := func(from, to int) {
+		for to > from && c.mem[to-1] == 0 {
+			to--
+		}
+		n := to - from
+		if n > 15 {
+			n = height
+		}
+		for ; to-from >= 2; from += n {
+			if to-from < n {
+				n = to - from
+			}
+			refs = append(refs, SpriteRef{uint16(from), n})
+		}
+	}
+	start := -1
+	for a := ProgramOffset; a <= end; a++ {
+		gap := a == end || used[a] || (c.mem[a] == 0 && a+1 < end && c.mem[a+1] == 0)
+		switch {
+		case gap && start >= 0:
+			add(start, a)
+			start = -1
+		case !gap && start < 0 && c.mem[a] != 0:
+			start = a
+		}
+	}
+	return refs
+}
+
+// spritePixels decodes the sprite at addr: 8xheight, or 16x16 when height is 0.
+func spritePixels(mem []byte, addr uint16, height int) [][]bool {
+	w, bytesPerRow := 8, 1
+	if height == 0 {
+		w, height, bytesPerRow = 16, 16, 2
+	}
+	px := make([][]bool, height)
+	for y := range px {
+		px[y] = make([]bool, w)
+		for x := 0; x < w; x++ {
+			b := mem[(int(addr)+y*bytesPerRow+x/8)&AddressMask]
+			px[y][x] = b&(0x80>>(x%8)) != 0
+		}
+	}
+	return px
+}
+
+// fontRefs are the built-in hex digits at CharacterSpritesOffset.
+func fontRefs() []SpriteRef {
+	refs := []SpriteRef{}
+	for d := 0; d < 16; d++ {
+		refs = append(refs, SpriteRef{uint16(CharacterSpritesOffset + d*CharacterSpriteBytes), CharacterSpriteBytes})
+	}
+	return refs
+}
+
+const (
+	spriteCell    = 18 // 16 pixels and a border
+	spriteColumns = 16
+)
+
+var (
+	spriteBackground = color.RGBA{32, 32, 32, 255}
+	spriteCellColor  = color.RGBA{0, 0, 0, 255}
+	spritePixelColor = color.RGBA{0, 255, 0, 255}
+)
+
+// WriteSpriteSheet writes a PNG with the built-in font on the first row and
+// refs below it, spriteColumns per row, every pixel scale x scale.
+func WriteSpriteSheet(w io.Writer, rom []byte, refs []SpriteRef, scale int) error {
+	c := NewChip8(rom, Quirks{})
+	all := append(fontRefs(), refs...)
+	rows := 1 + (len(refs)+spriteColumns-1)/spriteColumns
+
+	img := image.NewRGBA(image.Rect(0, 0, spriteColumns*spriteCell*scale, rows*spriteCell*scale))
+	fill(img, img.Bounds(), spriteBackground)
+	// the 16 font glyphs fill the first row exactly
+	for cell, r := range all {
+		x0 := (cell%spriteColumns*spriteCell + 1) * scale
+		y0 := (cell/spriteColumns*spriteCell + 1) * scale
+		fill(img, image.Rect(x0, y0, x0+16*scale, y0+16*scale), spriteCellColor)
+		for y, row := range spritePixels(c.mem[:], r.Addr, r.Height) {
+			for x, on := range row {
+				if on {
+					fill(img, image.Rect(x0+x*scale, y0+y*scale, x0+(x+1)*scale, y0+(y+1)*scale), spritePixelColor)
+				}
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
+func fill(img *image.RGBA, r image.Rectangle, c color.Color) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Set(x, y, c)
+		}
+	}
+}
+
+// spriteView is the state of the sprite panel: the address shown, following
+// I unless moved, and the height, taken from the last DXYN unless set.
+type spriteView struct {
+	addr   uint16
+	follow bool
+	height int
+}
+
+func newSpriteView() *spriteView {
+	return &spriteView{follow: true}
+}
+
+func (s *spriteView) update(c *Chip8) {
+	if s.follow {
+		s.addr = c.i & AddressMask
+	}
+}
+
+// spriteHeight is the height of the 8xN sprite shown.
+func (s *spriteView) spriteHeight(c *Chip8) int {
+	if s.height > 0 {
+		return s.height
+	}
+	if c.spriteN > 0 {
+		return int(c.spriteN)
+	}
+	return 15
+}
+
+func (s *spriteView) move(delta int) {
+	s.follow = false
+	s.addr = uint16(int(s.addr)+delta) & AddressMask
+}
+
+func (s *spriteView) resize(c *Chip8, delta int) {
+	h := s.spriteHeight(c) + delta
+	if h >= 1 && h <= 15 {
+		s.height = h
+	}
+}
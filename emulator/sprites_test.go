@@ -0,0 +1,78 @@
+package emulator
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// draws 3 and 5 rows at 0x20C and a 16x16 sprite at 0x20E
+var spritesROM = assemble(
+	0xA20C, 0xD013, 0xD015, 0xA20E, 0xD010,
+	0x120A,
+	0xF0F0, 0x9090, 0xF0F0,
+)
+
+func TestFindSprites(t *testing.T) {
+	assert.Equal(t, []SpriteRef{{0x20C, 5}, {0x20E, 0}}, FindSprites(spritesROM))
+}
+
+func TestScanSprites(t *testing.T) {
+	rom := assemble(0xA206, 0xD015, 0x1204,
+		// drawn
+		0xF090, 0x9090, 0xF000,
+		// a sprite on its own and a table
+		0x0018, 0x3C18, 0x0000)
+	table := bytes.Repeat([]byte{0x81}, 12)
+	table[5] = 0
+	rom = append(rom, table...)
+	known := FindSprites(rom)
+	assert.Equal(t, []SpriteRef{{0x206, 5}}, known)
+	assert.Equal(t, []SpriteRef{{0x20D, 3}, {0x212, 12}}, ScanSprites(rom, known))
+
+	// cut like the sprites drawn
+	rom = append(rom, table...)
+	assert.Equal(t, []SpriteRef{{0x20D, 3}, {0x212, 5}, {0x217, 5}, {0x21C, 5}, {0x221, 5}, {0x226, 4}}, ScanSprites(rom, known))
+	assert.Empty(t, ScanSprites(spritesROM, FindSprites(spritesROM)))
+}
+
+func TestSpritePixels(t *testing.T) {
+	c := NewChip8(nil, Quirks{})
+	px := spritePixels(c.mem[:], CharacterSpritesOffset+8*CharacterSpriteBytes, CharacterSpriteBytes)
+	rows := []string{}
+	for _, row := range px {
+		s := ""
+		for _, on := range row {
+			if on {
+				s += "#"
+			} else {
+				s += "."
+			}
+		}
+		rows = append(rows, s)
+	}
+	assert.Equal(t, []string{"####....", "#..#....", "####....", "#..#....", "####...."}, rows)
+	assert.Len(t, spritePixels(c.mem[:], 0, 0), 16)
+	assert.Len(t, spritePixels(c.mem[:], 0, 0)[0], 16)
+}
+
+func TestWriteSpriteSheet(t *testing.T) {
+	var b bytes.Buffer
+	if !assert.NoError(t, WriteSpriteSheet(&b, spritesROM, FindSprites(spritesROM), 2)) {
+		return
+	}
+	img, err := png.Decode(&b)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, spriteColumns*spriteCell*2, img.Bounds().Dx())
+	assert.Equal(t, 2*spriteCell*2, img.Bounds().Dy())
+
+	// top left pixel of the digit 0 and of the sprite at 0x20C
+	r, g, _, _ := img.At(2, 2).RGBA()
+	assert.Equal(t, []uint32{0, 0xffff}, []uint32{r, g})
+	r, g, _, _ = img.At(2, (spriteCell+1)*2).RGBA()
+	assert.Equal(t, []uint32{0, 0xffff}, []uint32{r, g})
+}
@@ -0,0 +1,73 @@
+package emulator
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+)
+
+// snapshot is the machine state kept in a save state file.
+type snapshot struct {
+	Mem    [4096]uint8
+	PC     uint16
+	V      [16]uint8
+	I      uint16
+	DT     uint8
+	ST     uint8
+	SP     uint8
+	Stack  [16]uint16
+	Depth  uint8
+	Disp   [64 * 32]uint8
+	Cycles uint64
+}
+
+func (c *Chip8) WriteState(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(snapshot{
+		Mem: c.mem, PC: c.pc, V: c.v, I: c.i, DT: c.dt, ST: c.st, SP: c.sp,
+		Stack: c.stack, Depth: c.depth, Disp: c.disp, Cycles: c.cycles,
+	})
+}
+
+// ReadState restores a state written by WriteState. States whose stack
+// would take the machine out of bounds, or with pixels other than 0 and 1,
+// are rejected and leave c as it was.
+func (c *Chip8) ReadState(r io.Reader) error {
+	var s snapshot
+	if err := gob.NewDecoder(r).Decode(&s); err != nil {
+		return err
+	}
+	if int(s.SP) >= len(s.Stack) || int(s.Depth) > len(s.Stack) {
+		return fmt.Errorf("corrupt state: SP=%X with %d return addresses", s.SP, s.Depth)
+	}
+	for i, v := range s.Disp {
+		if v > 1 {
+			return fmt.Errorf("corrupt state: pixel %d,%d is %d", i%Chip8DisplayW, i/Chip8DisplayW, v)
+		}
+	}
+	s.PC &= AddressMask
+	c.mem, c.pc, c.v, c.i, c.dt, c.st, c.sp = s.Mem, s.PC, s.V, s.I, s.DT, s.ST, s.SP
+	c.stack, c.depth, c.disp, c.cycles = s.Stack, s.Depth, s.Disp, s.Cycles
+	return nil
+}
+
+func (c *Chip8) SaveState(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := c.WriteState(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (c *Chip8) LoadState(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.ReadState(f)
+}
@@ -0,0 +1,44 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadState(t *testing.T) {
+	c := NewChip8(assemble(0x6105, 0x2300), Quirks{})
+	c.RunFrame()
+	var b bytes.Buffer
+	assert.NoError(t, c.WriteState(&b))
+
+	restored := NewChip8(nil, Quirks{})
+	assert.NoError(t, restored.ReadState(&b))
+	assert.Equal(t, c.v, restored.v)
+	assert.Equal(t, c.pc, restored.pc)
+	assert.Equal(t, c.callStack(), restored.callStack())
+}
+
+func TestReadCorruptState(t *testing.T) {
+	// CALL 300 at 200
+	rom := assemble(0x2300)
+	bright := snapshot{SP: 0x0f}
+	bright.Disp[65] = 2
+	for _, s := range []snapshot{{SP: 200}, {SP: 0x0f, Depth: 17}, bright} {
+		var b bytes.Buffer
+		assert.NoError(t, gob.NewEncoder(&b).Encode(s))
+		c := NewChip8(rom, Quirks{})
+		assert.Error(t, c.ReadState(&b))
+		assert.Equal(t, uint16(0x200), c.pc)
+		assert.NotPanics(t, func() { c.RunFrame(); screenLines(c) })
+	}
+
+	// PC wraps into memory
+	var b bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&b).Encode(snapshot{PC: 0xf202, SP: 0x0f}))
+	c := NewChip8(rom, Quirks{})
+	assert.NoError(t, c.ReadState(&b))
+	assert.Equal(t, uint16(0x202), c.pc)
+}
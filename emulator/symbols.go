@@ -0,0 +1,147 @@
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Symbols maps assembler labels to addresses.
+type Symbols struct {
+	names  map[uint16]string
+	addrs  map[string]uint16
+	sorted []uint16
+}
+
+// LoadSymbols reads a symbol file. One label per line is accepted in any of
+// the usual assembler layouts:
+//
+//	draw_ball = 0x2A4
+//	draw_ball: $2A4
+//	2A4 draw_ball
+//
+// Empty lines and lines starting with '#', ';' or "//" are ignored.
+func LoadSymbols(path string) (*Symbols, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s, err := ParseSymbols(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+func ParseSymbols(r io.Reader) (*Symbols, error) {
+	s := &Symbols{names: map[uint16]string{}, addrs: map[string]uint16{}}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == '=' || r == ':'
+		})
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a label and an address", n)
+		}
+
+		a0, prefixed0 := parseSymbolAddress(fields[0])
+		a1, prefixed1 := parseSymbolAddress(fields[1])
+		var name string
+		var addr int
+		switch {
+		case prefixed1 || (!prefixed0 && a1 >= 0 && a0 < 0):
+			name, addr = fields[0], a1
+		case prefixed0 || (a0 >= 0 && a1 < 0):
+			name, addr = fields[1], a0
+		case a0 >= 0 && a1 >= 0:
+			// both look like numbers: "label = addr" or "addr label"
+			if strings.ContainsAny(line, "=:") {
+				name, addr = fields[0], a1
+			} else {
+				name, addr = fields[1], a0
+			}
+		default:
+			return nil, fmt.Errorf("line %d: invalid address", n)
+		}
+		s.add(name, uint16(addr)&AddressMask)
+	}
+	return s, scanner.Err()
+}
+
+// parseSymbolAddress returns -1 if s is not an address. prefixed reports
+// whether it was written with 0x or $.
+func parseSymbolAddress(s string) (int, bool) {
+	prefixed := false
+	for _, p := range []string{"0x", "0X", "$"} {
+		if strings.HasPrefix(s, p) {
+			s, prefixed = s[len(p):], true
+		}
+	}
+	v, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return -1, false
+	}
+	return int(v), prefixed
+}
+
+func (s *Symbols) add(name string, addr uint16) {
+	if _, ok := s.names[addr]; !ok {
+		s.sorted = append(s.sorted, addr)
+		sort.Slice(s.sorted, func(i, j int) bool { return s.sorted[i] < s.sorted[j] })
+	}
+	s.names[addr] = name
+	s.addrs[name] = addr
+}
+
+// Lookup returns addr as "label" or "label+offset" relative to the closest
+// label at or below it, or "" if there is none.
+func (s *Symbols) Lookup(addr uint16) string {
+	if s == nil {
+		return ""
+	}
+	i := sort.Search(len(s.sorted), func(i int) bool { return s.sorted[i] > addr }) - 1
+	if i < 0 {
+		return ""
+	}
+	base := s.sorted[i]
+	if base == addr {
+		return s.names[base]
+	}
+	return fmt.Sprintf("%s+%d", s.names[base], addr-base)
+}
+
+// Address resolves a label, optionally followed by +offset.
+func (s *Symbols) Address(name string) (uint16, bool) {
+	if s == nil {
+		return 0, false
+	}
+	offset := uint64(0)
+	if i := strings.LastIndex(name, "+"); i > 0 {
+		o, err := strconv.ParseUint(name[i+1:], 10, 16)
+		if err != nil {
+			return 0, false
+		}
+		name, offset = name[:i], o
+	}
+	addr, ok := s.addrs[name]
+	return (addr + uint16(offset)) & AddressMask, ok
+}
+
+// formatInstruction formats an executed instruction for the debug views,
+// using the label of pc when one is known.
+func formatInstruction(pc, op uint16, s *Symbols, q Quirks) string {
+	if label := s.Lookup(pc); label != "" {
+		return fmt.Sprintf("%s %s", label, disassemble(op, q))
+	}
+	return fmt.Sprintf("%03X-%04X %s", pc, op, disassemble(op, q))
+}
@@ -0,0 +1,50 @@
+package emulator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSymbols(t *testing.T) {
+	s, err := ParseSymbols(strings.NewReader(`
+; generated symbols
+main = 0x200
+draw_ball: $2A4
+2B0 move
+0x2C0 score
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "main", s.Lookup(0x200))
+	assert.Equal(t, "main+6", s.Lookup(0x206))
+	assert.Equal(t, "draw_ball+4", s.Lookup(0x2A8))
+	assert.Equal(t, "move", s.Lookup(0x2B0))
+	assert.Equal(t, "score+2", s.Lookup(0x2C2))
+	assert.Equal(t, "", s.Lookup(0x1FE))
+
+	addr, ok := s.Address("draw_ball+4")
+	assert.True(t, ok)
+	assert.Equal(t, uint16(0x2A8), addr)
+	_, ok = s.Address("missing")
+	assert.False(t, ok)
+}
+
+func TestParseSymbolsError(t *testing.T) {
+	_, err := ParseSymbols(strings.NewReader("main = nowhere\n"))
+	assert.EqualError(t, err, "line 1: invalid address")
+}
+
+func TestNilSymbols(t *testing.T) {
+	var s *Symbols
+	assert.Equal(t, "", s.Lookup(0x200))
+	assert.Equal(t, "200-00E0 CLS  ", formatInstruction(0x200, 0x00E0, s, Quirks{}))
+}
+
+func TestFormatInstructionWithSymbols(t *testing.T) {
+	s, _ := ParseSymbols(strings.NewReader("draw_ball 0x2A4\n"))
+	assert.Equal(t, "draw_ball+4 LD   VA,#05", formatInstruction(0x2A8, 0x6A05, s, Quirks{}))
+}
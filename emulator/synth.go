@@ -0,0 +1,119 @@
+package emulator
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+type Waveform int
+
+const (
+	WaveSquare Waveform = iota
+	WaveSine
+)
+
+var waveforms = map[string]Waveform{
+	"square": WaveSquare,
+	"sine":   WaveSine,
+}
+
+func ParseWaveform(s string) (Waveform, error) {
+	w, ok := waveforms[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown waveform %q (want square, sine)", s)
+	}
+	return w, nil
+}
+
+// SynthOptions describe the buzzer.
+type SynthOptions struct {
+	SampleRate int
+	Wave       Waveform
+	Frequency  float64       // Hz
+	Volume     float64       // 0-1
+	Ramp       time.Duration // fade in and out, against pops at the edges of a tone
+	Latency    time.Duration // most sound a frontend queues ahead of the speaker
+}
+
+// DefaultSynth is a 440Hz square wave at a quarter of full scale, queued 3
+// frames ahead.
+var DefaultSynth = SynthOptions{
+	SampleRate: WAVSampleRate,
+	Wave:       WaveSquare,
+	Frequency:  BeepFrequency,
+	Volume:     0.25,
+	Ramp:       5 * time.Millisecond,
+	Latency:    50 * time.Millisecond,
+}
+
+// Synth renders the buzzer to samples. The wave runs on while the tone is
+// off, so a tone always continues the phase of the last one, and turning
+// the tone on or off fades rather than cuts.
+type Synth struct {
+	SynthOptions
+	on     bool
+	phase  float64 // position in the period, 0-1
+	level  float64 // envelope, 0-1
+	frames int     // rendered by frame
+}
+
+func NewSynth(o SynthOptions) *Synth {
+	return &Synth{SynthOptions: o}
+}
+
+// SetTone turns the tone on or off from the next sample on.
+func (s *Synth) SetTone(on bool) {
+	s.on = on
+}
+
+// Read renders the next len(out) samples, -1 to 1.
+func (s *Synth) Read(out []float32) {
+	step := 1.0
+	if s.Ramp > 0 {
+		step = 1 / (s.Ramp.Seconds() * float64(s.SampleRate))
+	}
+	for i := range out {
+		if s.on {
+			s.level = math.Min(1, s.level+step)
+		} else {
+			s.level = math.Max(0, s.level-step)
+		}
+
+		var v float64
+		switch s.Wave {
+		case WaveSquare:
+			v = 1
+			if s.phase < 0.5 {
+				v = -1
+			}
+		case WaveSine:
+			v = math.Sin(2 * math.Pi * s.phase)
+		}
+		out[i] = float32(v * s.Volume * s.level)
+
+		s.phase += s.Frequency / float64(s.SampleRate)
+		s.phase -= math.Floor(s.phase)
+	}
+}
+
+// ReadInt16 renders the next len(out) samples as 16-bit PCM.
+func (s *Synth) ReadInt16(out []int16) {
+	f := make([]float32, len(out))
+	s.Read(f)
+	for i, v := range f {
+		out[i] = int16(v * math.MaxInt16)
+	}
+}
+
+// frame renders one frame of samples with the tone on or off. Frames are
+// a sample longer now and then if the rate is not a multiple of 60, so that
+// a second is always SampleRate samples.
+func (s *Synth) frame(on bool) []int16 {
+	s.SetTone(on)
+	n := (s.frames+1)*s.SampleRate/VBlankFrequency - s.frames*s.SampleRate/VBlankFrequency
+	s.frames++
+	out := make([]int16, n)
+	s.ReadInt16(out)
+	return out
+}
@@ -0,0 +1,83 @@
+package emulator
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSynthSquare(t *testing.T) {
+	s := NewSynth(SynthOptions{SampleRate: 8, Frequency: 2, Volume: 0.5})
+	out := make([]float32, 8)
+	s.Read(out)
+	assert.Equal(t, make([]float32, 8), out)
+
+	s.SetTone(true)
+	s.Read(out)
+	assert.Equal(t, []float32{-0.5, -0.5, 0.5, 0.5, -0.5, -0.5, 0.5, 0.5}, out)
+}
+
+func TestSynthPhase(t *testing.T) {
+	// reads of any size continue the wave where the last one stopped,
+	// also across a pause in the tone
+	o := SynthOptions{SampleRate: 1000, Wave: WaveSine, Frequency: 30, Volume: 1}
+	whole := NewSynth(o)
+	whole.SetTone(true)
+	want := make([]float32, 100)
+	whole.Read(want)
+
+	parts := NewSynth(o)
+	parts.SetTone(true)
+	got := make([]float32, 0, 100)
+	for _, n := range []int{7, 1, 33, 20, 39} {
+		out := make([]float32, n)
+		parts.Read(out)
+		got = append(got, out...)
+	}
+	assert.Equal(t, want, got)
+
+	parts.SetTone(false)
+	parts.Read(make([]float32, 10))
+	parts.SetTone(true)
+	out := make([]float32, 1)
+	parts.Read(out)
+	assert.InDelta(t, math.Sin(2*math.Pi*30*110/1000), out[0], 1e-6)
+}
+
+func TestSynthRamp(t *testing.T) {
+	s := NewSynth(SynthOptions{SampleRate: 1000, Frequency: 50, Volume: 1, Ramp: 10 * time.Millisecond})
+	s.SetTone(true)
+	out := make([]float32, 30)
+	s.Read(out)
+	// 10 samples to full volume, no step larger than the ramp allows
+	// except where the square wave flips
+	for i, v := range out[:10] {
+		assert.InDelta(t, float64(i+1)/10, math.Abs(float64(v)), 1e-6)
+	}
+	assert.InDelta(t, 1, math.Abs(float64(out[29])), 1e-6)
+
+	s.SetTone(false)
+	s.Read(out)
+	for i, v := range out[:10] {
+		assert.InDelta(t, float64(9-i)/10, math.Abs(float64(v)), 1e-6)
+	}
+	assert.Equal(t, make([]float32, 20), out[10:])
+}
+
+func TestSynthInt16(t *testing.T) {
+	s := NewSynth(SynthOptions{SampleRate: 4, Frequency: 1, Volume: 1})
+	s.SetTone(true)
+	out := make([]int16, 4)
+	s.ReadInt16(out)
+	assert.Equal(t, []int16{-math.MaxInt16, -math.MaxInt16, math.MaxInt16, math.MaxInt16}, out)
+}
+
+func TestParseWaveform(t *testing.T) {
+	w, err := ParseWaveform("sine")
+	assert.NoError(t, err)
+	assert.Equal(t, WaveSine, w)
+	_, err = ParseWaveform("saw")
+	assert.Error(t, err)
+}
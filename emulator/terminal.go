@@ -0,0 +1,319 @@
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// KeyReleaseTimeout is how long a key stays pressed after its last keystroke.
+// Terminals report no key releases, only presses and auto repeat, which
+// starts after a delay of 250-600ms on most keyboards.
+const KeyReleaseTimeout = 500 * time.Millisecond
+
+var char2Key = map[byte]byte{
+	'4': 0x1, '5': 0x2, '6': 0x3, '7': 0xc,
+	'r': 0x4, 't': 0x5, 'y': 0x6, 'u': 0xd,
+	'f': 0x7, 'g': 0x8, 'h': 0x9, 'j': 0xe,
+	'v': 0xa, 'b': 0x0, 'n': 0xb, 'm': 0xf,
+}
+
+const (
+	ansiHome      = "\x1b[H"
+	ansiClear     = "\x1b[2J"
+	ansiHideCur   = "\x1b[?25l"
+	ansiShowCur   = "\x1b[?25h"
+	ansiReset     = "\x1b[0m"
+	ansiClearLine = "\x1b[K"
+	keyCtrlC      = 0x03
+	keyEsc        = 0x1b
+)
+
+// TerminalMessages is the number of message lines shown below the registers.
+const TerminalMessages = 3
+
+// terminal shows the emulator in a terminal, drawing two pixel rows per line
+// with Unicode half blocks.
+type terminal struct {
+	out      *bufio.Writer
+	keys     chan byte
+	pressed  [16]bool
+	released [16]time.Time
+	beeping  bool
+	ticker   *time.Ticker
+	restore  func()
+	esc      byte // the escape sequence being skipped: keyEsc, '[' or 'O', else 0
+	messages messageLines
+}
+
+func newTerminal(w io.Writer) *terminal {
+	return &terminal{out: bufio.NewWriter(w), keys: make(chan byte, 64)}
+}
+
+// messageLines keeps the last TerminalMessages lines written to it.
+type messageLines struct {
+	lines   []string
+	partial string
+	unshown int // lines written since the last Present
+}
+
+func (m *messageLines) Write(p []byte) (int, error) {
+	lines := strings.Split(m.partial+string(p), "\n")
+	m.partial = lines[len(lines)-1]
+	m.lines = append(m.lines, lines[:len(lines)-1]...)
+	m.unshown += len(lines) - 1
+	if len(m.lines) > TerminalMessages {
+		m.lines = m.lines[len(m.lines)-TerminalMessages:]
+	}
+	return len(p), nil
+}
+
+// Messages shows the emulator's messages below the registers, as the
+// terminal is raw and the screen is redrawn every frame.
+func (t *terminal) Messages() io.Writer {
+	return &t.messages
+}
+
+// NewTerminalFrontend runs the emulator in the terminal, which is in raw mode
+// from the first frame until the frontend is closed. Ctrl-C quits.
+func NewTerminalFrontend() Frontend {
+	t := newTerminal(os.Stdout)
+	t.ticker = time.NewTicker(time.Second / VBlankFrequency)
+	return Frontend{Video: t, Audio: t, Input: t}
+}
+
+func (t *terminal) start() {
+	restore, err := rawMode()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "raw mode:", err)
+		restore = func() {}
+	}
+	t.restore = restore
+	go readKeys(os.Stdin, t.keys)
+	fmt.Fprint(t.out, ansiClear, ansiHideCur)
+}
+
+func (t *terminal) Close() error {
+	t.ticker.Stop()
+	if t.restore == nil {
+		return nil
+	}
+	fmt.Fprint(t.out, ansiReset, ansiShowCur, "\r\n")
+	// messages written after the last frame
+	m := &t.messages
+	if m.unshown > len(m.lines) {
+		m.unshown = len(m.lines)
+	}
+	for _, l := range m.lines[len(m.lines)-m.unshown:] {
+		fmt.Fprint(t.out, l, "\r\n")
+	}
+	err := t.out.Flush()
+	t.restore()
+	return err
+}
+
+// rawMode switches stdin to raw mode with stty and returns a function that
+// restores the previous settings.
+func rawMode() (func(), error) {
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(saved) }, nil
+}
+
+func readKeys(r io.Reader, keys chan<- byte) {
+	b := make([]byte, 16)
+	for {
+		n, err := r.Read(b)
+		for _, c := range b[:n] {
+			keys <- c
+		}
+		if err != nil {
+			close(keys)
+			return
+		}
+	}
+}
+
+func (t *terminal) Poll(e *Emulator) []Event {
+	if t.restore == nil {
+		t.start()
+	}
+	return t.pollKeys(time.Now())
+}
+
+// pollKeys turns the keystrokes read since the last frame into events and
+// releases the keys that have not repeated within KeyReleaseTimeout.
+func (t *terminal) pollKeys(now time.Time) []Event {
+	var events []Event
+drain:
+	for {
+		select {
+		case c, ok := <-t.keys:
+			if !ok {
+				return append(events, Event{Kind: EventQuit})
+			}
+			if ev, ok := t.key(c, now); ok {
+				events = append(events, ev)
+			}
+		default:
+			break drain
+		}
+	}
+	// a lone ESC is the escape key, sequences arrive in one read
+	if t.esc == keyEsc {
+		t.esc = 0
+	}
+
+	for i, r := range t.released {
+		if t.pressed[i] && now.After(r) {
+			t.pressed[i] = false
+			events = append(events, Event{Kind: EventKeyUp, Key: uint8(i)})
+		}
+	}
+	return events
+}
+
+// char2Hotkey holds lower case letters only, key folds upper case into them.
+var char2Hotkey = map[byte]Hotkey{
+	' ':  HotkeyStep,
+	'\r': HotkeyResume,
+	'\n': HotkeyResume,
+	'z':  HotkeyReset,
+	'p':  HotkeyScreenshot,
+	'o':  HotkeyRecord,
+	'c':  HotkeyPalette,
+}
+
+// key turns a keystroke into an event. Escape sequences, such as those of
+// the arrow and function keys, are skipped.
+func (t *terminal) key(c byte, now time.Time) (Event, bool) {
+	switch t.esc {
+	case keyEsc:
+		t.esc = 0
+		if c == '[' || c == 'O' {
+			t.esc = c
+		}
+		return Event{}, false
+	case '[':
+		// parameters until the final byte
+		if c >= 0x40 && c <= 0x7e {
+			t.esc = 0
+		}
+		return Event{}, false
+	case 'O':
+		t.esc = 0
+		return Event{}, false
+	}
+	if c == keyEsc {
+		t.esc = keyEsc
+		return Event{}, false
+	}
+
+	if c >= 'A' && c <= 'Z' {
+		c += 'a' - 'A'
+	}
+	if i, ok := char2Key[c]; ok {
+		t.released[i] = now.Add(KeyReleaseTimeout)
+		if t.pressed[i] {
+			return Event{}, false
+		}
+		t.pressed[i] = true
+		return Event{Kind: EventKeyDown, Key: i}, true
+	}
+	if c == keyCtrlC {
+		return Event{Kind: EventQuit}, true
+	}
+	if h, ok := char2Hotkey[c]; ok {
+		return Event{Kind: EventHotkey, Hotkey: h}, true
+	}
+	return Event{}, false
+}
+
+// Tone rings the terminal bell when the sound timer starts.
+func (t *terminal) Tone(on bool) {
+	if on && !t.beeping {
+		fmt.Fprint(t.out, "\a")
+	}
+	t.beeping = on
+}
+
+func (t *terminal) Present(e *Emulator) {
+	fmt.Fprint(t.out, ansiHome, ansiColors(e.Palette()))
+	for _, line := range screenLines(e.chip8) {
+		fmt.Fprint(t.out, line, "\r\n")
+	}
+	fmt.Fprint(t.out, ansiReset)
+	for _, line := range registerLines(e.chip8, e.symbols, e.stepMode) {
+		fmt.Fprint(t.out, line, ansiClearLine, "\r\n")
+	}
+	m := &t.messages
+	for i := 0; i < TerminalMessages; i++ {
+		line := ""
+		if i < len(m.lines) {
+			line = m.lines[i]
+		}
+		fmt.Fprint(t.out, line, ansiClearLine, "\r\n")
+	}
+	m.unshown = 0
+	t.out.Flush()
+	if t.ticker != nil {
+		<-t.ticker.C
+	}
+}
+
+// ansiColors selects the foreground and background of p as 24-bit colors.
+// Each character cell has only these two, so the XO-CHIP plane colors are
+// not shown.
+func ansiColors(p Palette) string {
+	fg, bg := p.Foreground, p.Background
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm", fg.R, fg.G, fg.B, bg.R, bg.G, bg.B)
+}
+
+// screenLines renders disp with two pixel rows per line: the upper half
+// block is the top pixel, the lower half block the bottom one.
+func screenLines(c *Chip8) []string {
+	blocks := [4]string{" ", "▀", "▄", "█"}
+	lines := make([]string, 0, Chip8DisplayH/2)
+	for y := 0; y < Chip8DisplayH; y += 2 {
+		var b strings.Builder
+		for x := 0; x < Chip8DisplayW; x++ {
+			top := c.disp[y*Chip8DisplayW+x]
+			bottom := c.disp[(y+1)*Chip8DisplayW+x]
+			b.WriteString(blocks[top|bottom<<1])
+		}
+		lines = append(lines, b.String())
+	}
+	return lines
+}
+
+func registerLines(c *Chip8, sym *Symbols, stepMode bool) []string {
+	mode := "RUN "
+	if stepMode {
+		mode = "STEP"
+	}
+	v := make([]string, 0, len(c.v))
+	for i, r := range c.v {
+		v = append(v, fmt.Sprintf("V%X=%02X", i, r))
+	}
+	return []string{
+		fmt.Sprintf("%s PC=%03X I=%03X SP=%X DT=%02X ST=%02X  %s", mode, c.pc, c.i, c.sp, c.dt, c.st, formatInstruction(c.pc, c.opcodeAt(c.pc), sym, c.quirks)),
+		strings.Join(v[:8], " "),
+		strings.Join(v[8:], " "),
+		"SPACE step  RETURN run  Z reset  P screenshot  O record  C colors  Ctrl-C quit",
+	}
+}
@@ -0,0 +1,94 @@
+package emulator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScreenLines(t *testing.T) {
+	c := NewChip8(nil, Quirks{})
+	c.disp[0] = 1               // (0,0) top
+	c.disp[Chip8DisplayW+1] = 1 // (1,1) bottom
+	c.disp[2] = 1               // (2,0) and (2,1)
+	c.disp[Chip8DisplayW+2] = 1
+
+	lines := screenLines(c)
+	assert.Len(t, lines, Chip8DisplayH/2)
+	assert.Equal(t, []rune("▀▄█ "), []rune(lines[0])[:4])
+	assert.Equal(t, Chip8DisplayW, len([]rune(lines[1])))
+}
+
+func TestTerminalKeyRelease(t *testing.T) {
+	term := newTerminal(io.Discard)
+	now := time.Now()
+
+	term.keys <- 'v'
+	term.keys <- 'G'
+	assert.Equal(t, []Event{{Kind: EventKeyDown, Key: 0xa}, {Kind: EventKeyDown, Key: 0x8}}, term.pollKeys(now))
+
+	// auto repeat keeps V pressed
+	term.keys <- 'v'
+	assert.Empty(t, term.pollKeys(now.Add(KeyReleaseTimeout-time.Millisecond)))
+	assert.Equal(t, []Event{{Kind: EventKeyUp, Key: 0x8}}, term.pollKeys(now.Add(KeyReleaseTimeout+time.Millisecond)))
+
+	term.keys <- ' '
+	term.keys <- 'Z'
+	term.keys <- keyCtrlC
+	assert.Equal(t, []Event{{Kind: EventHotkey, Hotkey: HotkeyStep}, {Kind: EventHotkey, Hotkey: HotkeyReset}, {Kind: EventQuit}}, term.pollKeys(now))
+}
+
+func TestTerminalBell(t *testing.T) {
+	var out bytes.Buffer
+	term := newTerminal(&out)
+	term.Tone(true)
+	term.Tone(true)
+	term.Tone(false)
+	term.Tone(true)
+	term.out.Flush()
+	assert.Equal(t, "\a\a", out.String())
+}
+
+func TestTerminalEscapeSequences(t *testing.T) {
+	term := newTerminal(io.Discard)
+	now := time.Now()
+	// arrow down, F1, Ctrl-T, then V
+	for _, c := range []byte("\x1b[B\x1bOP\x14\x1b[1;5Av") {
+		term.keys <- c
+	}
+	assert.Equal(t, []Event{{Kind: EventKeyDown, Key: 0xa}}, term.pollKeys(now))
+
+	// a lone escape key does not swallow the next keystroke
+	term.keys <- keyEsc
+	assert.Empty(t, term.pollKeys(now))
+	term.keys <- 'B'
+	assert.Equal(t, []Event{{Kind: EventKeyDown, Key: 0x0}}, term.pollKeys(now))
+}
+
+func TestTerminalMessages(t *testing.T) {
+	var out bytes.Buffer
+	term := newTerminal(&out)
+	e := NewEmulator(toneROM, false, QuirkPresets["default"], Frontend{term, term, term})
+	assert.Equal(t, term.Messages(), e.log)
+
+	for i := 1; i <= 4; i++ {
+		fmt.Fprintf(e.log, "message %d\n", i)
+	}
+	term.Present(e)
+	screen := out.String()
+	assert.NotContains(t, screen, "message 1")
+	assert.Contains(t, screen, "message 2"+ansiClearLine+"\r\nmessage 3"+ansiClearLine+"\r\nmessage 4"+ansiClearLine+"\r\n")
+
+	// shown again on the screen left behind
+	out.Reset()
+	term.restore = func() {}
+	fmt.Fprint(e.log, "last ")
+	fmt.Fprintln(e.log, "words")
+	term.ticker = time.NewTicker(time.Hour)
+	assert.NoError(t, term.Close())
+	assert.Equal(t, ansiReset+ansiShowCur+"\r\nlast words\r\n", out.String())
+}
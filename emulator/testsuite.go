@@ -0,0 +1,279 @@
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Suite describes a test ROM that reports its results on screen.
+// After running Frames frames headlessly, every Check is decoded from the display.
+type Suite struct {
+	Name   string
+	Frames int
+	Setup  func(c *Chip8)            // called once before the first frame
+	Input  func(c *Chip8, frame int) // called before every frame (keypad tests)
+	Checks []Check
+}
+
+// Check is a single result cell. It passes when the pixels at X,Y match Pass,
+// a glyph written as rows of '#' (set) and '.' (clear).
+type Check struct {
+	Name string
+	X, Y int
+	Pass []string
+}
+
+type CheckResult struct {
+	Name string
+	Pass bool
+	Got  []string
+}
+
+type Report struct {
+	Suite   string
+	Results []CheckResult
+	Screen  string
+}
+
+// RunFrames runs the chip8 headlessly for the given number of 60Hz frames.
+func RunFrames(c *Chip8, frames int, input func(c *Chip8, frame int)) {
+	for f := 0; f < frames; f++ {
+		if input != nil {
+			input(c, f)
+		}
+		c.RunFrame()
+	}
+}
+
+func (s *Suite) Run(rom []byte, q Quirks) *Report {
+	c := NewChip8(rom, q)
+	if s.Setup != nil {
+		s.Setup(c)
+	}
+	RunFrames(c, s.Frames, s.Input)
+
+	r := &Report{Suite: s.Name, Screen: c.screenText()}
+	for _, ch := range s.Checks {
+		got := c.readGlyph(ch.X, ch.Y, len(ch.Pass[0]), len(ch.Pass))
+		r.Results = append(r.Results, CheckResult{
+			Name: ch.Name,
+			Pass: strings.Join(got, "\n") == strings.Join(ch.Pass, "\n"),
+			Got:  got,
+		})
+	}
+	return r
+}
+
+func (r *Report) Passed() bool {
+	return len(r.Failed()) == 0
+}
+
+func (r *Report) Failed() []string {
+	failed := []string{}
+	for _, res := range r.Results {
+		if !res.Pass {
+			failed = append(failed, res.Name)
+		}
+	}
+	return failed
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", r.Suite)
+	for _, res := range r.Results {
+		status := "PASS"
+		if !res.Pass {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "  %s %s\n", status, res.Name)
+	}
+	return b.String()
+}
+
+func (c *Chip8) readGlyph(x, y, w, h int) []string {
+	rows := make([]string, h)
+	for iy := 0; iy < h; iy++ {
+		row := make([]byte, w)
+		for ix := 0; ix < w; ix++ {
+			row[ix] = '.'
+			tx, ty := x+ix, y+iy
+			if tx >= 0 && ty >= 0 && tx < Chip8DisplayW && ty < Chip8DisplayH && c.disp[ty*Chip8DisplayW+tx] != 0 {
+				row[ix] = '#'
+			}
+		}
+		rows[iy] = string(row)
+	}
+	return rows
+}
+
+func (c *Chip8) screenText() string {
+	return strings.Join(c.readGlyph(0, 0, Chip8DisplayW, Chip8DisplayH), "\n")
+}
+
+// LoadSuite reads a suite file for the quirks preset named preset, see
+// ParseSuite.
+func LoadSuite(path, preset string) (*Suite, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s, err := ParseSuite(f, preset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// ParseSuite reads a suite describing where a test ROM prints its results:
+//
+//	name = flags
+//	frames = 300
+//	poke = 1FF 4         # memory byte set before the first frame
+//	key = 120 A          # key held down during frame 120
+//	glyph ok             # rows of '#' and '.' up to a blank line
+//	.....#
+//	#...#.
+//	.#.#..
+//	..#...
+//
+//	check = 8XY4 carry 40 10 ok     # name x y glyph
+//
+// Lines after a [preset] header only apply to that quirks preset, so the
+// expected results can differ between presets.
+func ParseSuite(r io.Reader, preset string) (*Suite, error) {
+	s := &Suite{}
+	glyphs := map[string][]string{}
+	pokes := map[uint16]uint8{}
+	keys := map[int][]uint8{}
+	checks := [][2]string{}
+
+	glyph, section := "", ""
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			glyph = ""
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			glyph, section = "", line[1:len(line)-1]
+			continue
+		}
+		if section != "" && section != preset {
+			continue
+		}
+		if glyph != "" && strings.Trim(line, "#.") == "" {
+			if rows := glyphs[glyph]; len(rows) > 0 && len(rows[0]) != len(line) {
+				return nil, fmt.Errorf("line %d: glyph %s rows differ in width", n, glyph)
+			}
+			glyphs[glyph] = append(glyphs[glyph], line)
+			continue
+		}
+		glyph = ""
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if f := strings.Fields(line); f[0] == "glyph" && len(f) == 2 {
+			glyph = f[1]
+			glyphs[glyph] = nil
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		fields := strings.Fields(value)
+		var err error
+		switch key {
+		case "name":
+			s.Name = value
+		case "frames":
+			if s.Frames, err = strconv.Atoi(value); err == nil && s.Frames <= 0 {
+				err = fmt.Errorf("frames must be positive")
+			}
+		case "poke":
+			var addr, v uint64
+			if len(fields) != 2 {
+				err = fmt.Errorf("expected poke = address value")
+			} else if addr, err = strconv.ParseUint(fields[0], 16, 12); err == nil {
+				v, err = strconv.ParseUint(fields[1], 16, 8)
+			}
+			pokes[uint16(addr)] = uint8(v)
+		case "key":
+			var frame int
+			var k uint64
+			if len(fields) != 2 {
+				err = fmt.Errorf("expected key = frame key")
+			} else if frame, err = strconv.Atoi(fields[0]); err == nil {
+				k, err = strconv.ParseUint(fields[1], 16, 4)
+			}
+			keys[frame] = append(keys[frame], uint8(k))
+		case "check":
+			// the name may contain spaces, the position and glyph are the
+			// last three fields
+			if len(fields) < 4 {
+				err = fmt.Errorf("expected check = name x y glyph")
+				break
+			}
+			checks = append(checks, [2]string{strings.Join(fields[:len(fields)-3], " "), strings.Join(fields[len(fields)-3:], " ")})
+		default:
+			err = fmt.Errorf("unknown key %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if s.Frames == 0 {
+		return nil, fmt.Errorf("missing frames")
+	}
+
+	for _, ch := range checks {
+		var x, y int
+		var name string
+		if _, err := fmt.Sscan(ch[1], &x, &y, &name); err != nil {
+			return nil, fmt.Errorf("check %s: %v", ch[0], err)
+		}
+		if x < 0 || y < 0 {
+			return nil, fmt.Errorf("check %s: negative position %d,%d", ch[0], x, y)
+		}
+		rows, ok := glyphs[name]
+		if !ok || len(rows) == 0 {
+			return nil, fmt.Errorf("check %s: unknown glyph %q", ch[0], name)
+		}
+		s.Checks = append(s.Checks, Check{ch[0], x, y, rows})
+	}
+	if len(pokes) > 0 {
+		s.Setup = func(c *Chip8) {
+			for addr, v := range pokes {
+				c.mem[addr] = v
+			}
+		}
+	}
+	if len(keys) > 0 {
+		s.Input = func(c *Chip8, frame int) {
+			for _, k := range keys[frame-1] {
+				c.SetKey(k, false)
+			}
+			for _, k := range keys[frame] {
+				c.SetKey(k, true)
+			}
+		}
+	}
+	return s, nil
+}
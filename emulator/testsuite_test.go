@@ -0,0 +1,224 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func assemble(ops ...uint16) []byte {
+	b := make([]byte, len(ops)*2)
+	for i, op := range ops {
+		binary.BigEndian.PutUint16(b[i*2:], op)
+	}
+	return b
+}
+
+func digitGlyph(d uint8) []string {
+	r := strings.NewReplacer("0", ".", "1", "#")
+	rows := []string{}
+	for _, v := range characterSprites[int(d)*CharacterSpriteBytes : int(d+1)*CharacterSpriteBytes] {
+		rows = append(rows, r.Replace(fmt.Sprintf("%04b", v>>4)))
+	}
+	return rows
+}
+
+// quirksROM stores one result per quirk in V5-V9 and prints them as digits
+var quirksROM = assemble(
+	// vf reset: V5=VF after 8XY1
+	0x6F05, 0x6101, 0x8011, 0x85F0,
+	// memory: V6=1 if FX55 incremented I
+	0xA300, 0x6001, 0xF055, 0x6002, 0xF055, 0xA300, 0xF065, 0x8600,
+	// shift: V7=3 if 8XY6 shifted Vx in place
+	0x6106, 0x6202, 0x8126, 0x8710,
+	// jump: V8=2 if BXNN used VX
+	0x6000, 0x6204, 0xB22A, 0x0000, 0x0000, 0x6801, 0x1230, 0x6802,
+	// wrap: V9=1 if the sprite drawn at x=62 collided at x=0
+	0x6308, 0xF329, 0x6A3E, 0x6B14, 0xDAB5, 0x6C00, 0xDCB5, 0x89F0, 0xDAB5, 0xDCB5,
+	// print V5-V9
+	0x6D00, 0x6E00,
+	0xF529, 0xDED5, 0x7E05,
+	0xF629, 0xDED5, 0x7E05,
+	0xF729, 0xDED5, 0x7E05,
+	0xF829, 0xDED5, 0x7E05,
+	0xF929, 0xDED5,
+	0x1264,
+)
+
+func quirksSuite(vfReset, memory, shift, jump, wrap uint8) *Suite {
+	return &Suite{
+		Name:   "quirks",
+		Frames: 20,
+		Checks: []Check{
+			{"vf reset", 0, 0, digitGlyph(vfReset)},
+			{"memory", 5, 0, digitGlyph(memory)},
+			{"shift", 10, 0, digitGlyph(shift)},
+			{"jump", 15, 0, digitGlyph(jump)},
+			{"wrap", 20, 0, digitGlyph(wrap)},
+		},
+	}
+}
+
+func TestQuirkPresets(t *testing.T) {
+	tests := []struct {
+		preset string
+		suite  *Suite
+	}{
+		{"default", quirksSuite(5, 2, 3, 1, 0)},
+		{"vip", quirksSuite(0, 1, 1, 1, 0)},
+		{"schip", quirksSuite(5, 2, 3, 2, 0)},
+		{"xochip", quirksSuite(5, 1, 1, 1, 1)},
+	}
+	for _, test := range tests {
+		t.Run(test.preset, func(t *testing.T) {
+			r := test.suite.Run(quirksROM, QuirkPresets[test.preset])
+			assert.Empty(t, r.Failed(), "%s\n%s", r, r.Screen)
+		})
+	}
+}
+
+func TestSuiteReportsFailure(t *testing.T) {
+	r := quirksSuite(0, 0, 0, 0, 0).Run(quirksROM, QuirkPresets["vip"])
+	assert.Equal(t, []string{"memory", "shift", "jump"}, r.Failed())
+	assert.False(t, r.Passed())
+}
+
+const quirksSuiteFile = `name = quirks
+frames = 20
+poke = 300 07 # overwritten by the rom
+
+glyph 0
+####
+#..#
+#..#
+#..#
+####
+
+glyph 1
+..#.
+.##.
+..#.
+..#.
+.###
+
+glyph 5
+####
+#...
+####
+...#
+####
+
+check = jump 15 0 1
+check = wrap 20 0 0
+
+[default]
+check = vf reset 0 0 5
+
+[vip]
+check = vf reset 0 0 0
+check = memory 5 0 1
+`
+
+func TestParseSuite(t *testing.T) {
+	s, err := ParseSuite(strings.NewReader(quirksSuiteFile), "vip")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "quirks", s.Name)
+	assert.Equal(t, 20, s.Frames)
+	assert.Equal(t, []string{"jump", "wrap", "vf reset", "memory"}, []string{s.Checks[0].Name, s.Checks[1].Name, s.Checks[2].Name, s.Checks[3].Name})
+	assert.Equal(t, digitGlyph(1), s.Checks[0].Pass)
+	r := s.Run(quirksROM, QuirkPresets["vip"])
+	assert.True(t, r.Passed(), "%s\n%s", r, r.Screen)
+
+	s, err = ParseSuite(strings.NewReader(quirksSuiteFile), "default")
+	assert.NoError(t, err)
+	assert.Len(t, s.Checks, 3)
+	r = s.Run(quirksROM, QuirkPresets["vip"])
+	assert.Equal(t, []string{"vf reset"}, r.Failed())
+
+	for _, f := range []string{"frames = 0", "frames = 1\nkey = 5", "frames = 1\ncheck = a = 0 0 ok", "frames = 1\nglyph ok\n##\n###", "frames = 1\nspeed = 2", "frames = 1\nglyph ok\n#\n\ncheck = a -1 0 ok"} {
+		_, err := ParseSuite(strings.NewReader(f), "vip")
+		assert.Error(t, err, f)
+	}
+}
+
+func TestSuiteKeys(t *testing.T) {
+	// wait for a key and print it
+	rom := assemble(0xF00A, 0xF029, 0xD115, 0x1206)
+	s, err := ParseSuite(strings.NewReader("frames = 5\nkey = 2 1\nglyph 1\n..#.\n.##.\n..#.\n..#.\n.###\n\ncheck = key 0 0 1\n"), "")
+	if assert.NoError(t, err) {
+		r := s.Run(rom, QuirkPresets["default"])
+		assert.True(t, r.Passed(), "%s\n%s", r, r.Screen)
+	}
+}
+
+// communitySuites are the suite files shipped for the opcode, flags, quirks
+// and keypad roms of https://github.com/Timendus/chip8-test-suite.
+const communitySuites = "testdata/suites"
+
+func TestShippedSuites(t *testing.T) {
+	suites, _ := filepath.Glob(filepath.Join(communitySuites, "*.suite"))
+	assert.Len(t, suites, 4)
+	for _, path := range suites {
+		for preset := range QuirkPresets {
+			_, err := LoadSuite(path, preset)
+			assert.NoError(t, err)
+		}
+	}
+}
+
+// TestCommunitySuites runs the test roms in $CHIP8_TEST_ROMS under every
+// quirks preset. A rom.ch8 is checked with the rom.suite next to it, or the
+// one of the same name in communitySuites.
+func TestCommunitySuites(t *testing.T) {
+	dir := os.Getenv("CHIP8_TEST_ROMS")
+	if dir == "" {
+		t.Skip("CHIP8_TEST_ROMS is not set")
+	}
+	roms, _ := filepath.Glob(filepath.Join(dir, "*.ch8"))
+	suites := []string{}
+	for _, rom := range roms {
+		path := strings.TrimSuffix(rom, ".ch8") + ".suite"
+		if _, err := os.Stat(path); err != nil {
+			path = filepath.Join(communitySuites, filepath.Base(path))
+		}
+		if _, err := os.Stat(path); err == nil {
+			suites = append(suites, path)
+		}
+	}
+	if len(suites) == 0 {
+		t.Fatalf("no roms with a .suite file in %s", dir)
+	}
+	presets := []string{}
+	for name := range QuirkPresets {
+		presets = append(presets, name)
+	}
+	sort.Strings(presets)
+
+	for _, path := range suites {
+		rom, err := os.ReadFile(filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), ".suite")+".ch8"))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		for _, preset := range presets {
+			s, err := LoadSuite(path, preset)
+			if !assert.NoError(t, err) {
+				break
+			}
+			if len(s.Checks) == 0 {
+				continue
+			}
+			t.Run(filepath.Base(path)+"/"+preset, func(t *testing.T) {
+				r := s.Run(rom, QuirkPresets[preset])
+				assert.Empty(t, r.Failed(), "%s\n%s", r, r.Screen)
+			})
+		}
+	}
+}
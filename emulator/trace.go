@@ -0,0 +1,181 @@
+package emulator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+const (
+	TraceText   = "text"
+	TraceBinary = "binary"
+
+	traceMagic = "C8TR"
+)
+
+// Tracer streams every executed instruction to a file.
+//
+// Text lines look like
+//
+//	12 2D8 F265 LD   V2,[I]     V0=01 V1=02 I=02F5
+//
+// (cycle, pc, opcode, mnemonic and the registers changed by the instruction).
+// With Symbols set, the label of pc is written before the mnemonic.
+// Binary records are: uvarint cycle, uint16 pc, uint16 opcode, uint32 mask of
+// changed registers (bit 0-15 V0-VF, 16 I, 17 DT, 18 ST, 19 SP) followed by
+// the changed values, one byte each except two bytes for I. Multi-byte values
+// are big endian and binary files start with "C8TR".
+//
+// Only instructions with From <= pc <= To are written. When Limit is positive
+// and the file would grow past it, the file is moved to path.1 and a new one
+// is started.
+type Tracer struct {
+	From    uint16
+	To      uint16
+	Limit   int64
+	Symbols *Symbols
+	path    string
+	format  string
+	f       *os.File
+	w       *bufio.Writer
+	size    int64
+	lines   int
+	buf     bytes.Buffer
+	err     error
+}
+
+func NewTracer(path, format string) (*Tracer, error) {
+	if format != TraceText && format != TraceBinary {
+		return nil, fmt.Errorf("unknown trace format %q", format)
+	}
+	t := &Tracer{To: AddressMask, path: path, format: format}
+	if err := t.open(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *Tracer) open() error {
+	f, err := os.Create(t.path)
+	if err != nil {
+		return err
+	}
+	t.f = f
+	t.w = bufio.NewWriter(f)
+	t.size = 0
+	t.lines = 0
+	if t.format == TraceBinary {
+		n, _ := t.w.WriteString(traceMagic)
+		t.size += int64(n)
+	}
+	return nil
+}
+
+func (t *Tracer) rotate() error {
+	if err := t.w.Flush(); err != nil {
+		return err
+	}
+	if err := t.f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(t.path, t.path+".1"); err != nil {
+		return err
+	}
+	return t.open()
+}
+
+func (t *Tracer) trace(cycle uint64, pc, op uint16, mnemonic string, before, after registers) {
+	if t.err != nil || pc < t.From || pc > t.To {
+		return
+	}
+
+	t.buf.Reset()
+	if t.format == TraceText {
+		t.formatText(cycle, pc, op, mnemonic, before, after)
+	} else {
+		t.formatBinary(cycle, pc, op, before, after)
+	}
+
+	if t.Limit > 0 && t.size+int64(t.buf.Len()) > t.Limit && t.lines > 0 {
+		if t.err = t.rotate(); t.err != nil {
+			return
+		}
+	}
+	n, err := t.w.Write(t.buf.Bytes())
+	t.size += int64(n)
+	t.lines++
+	t.err = err
+}
+
+func (t *Tracer) formatText(cycle uint64, pc, op uint16, mnemonic string, before, after registers) {
+	fmt.Fprintf(&t.buf, "%d %03X %04X ", cycle, pc, op)
+	if label := t.Symbols.Lookup(pc); label != "" {
+		fmt.Fprintf(&t.buf, "%s: ", label)
+	}
+	fmt.Fprintf(&t.buf, "%-14s", mnemonic)
+	for i := range after.v {
+		if before.v[i] != after.v[i] {
+			fmt.Fprintf(&t.buf, " V%X=%02X", i, after.v[i])
+		}
+	}
+	if before.i != after.i {
+		fmt.Fprintf(&t.buf, " I=%04X", after.i)
+	}
+	if before.dt != after.dt {
+		fmt.Fprintf(&t.buf, " DT=%02X", after.dt)
+	}
+	if before.st != after.st {
+		fmt.Fprintf(&t.buf, " ST=%02X", after.st)
+	}
+	if before.sp != after.sp {
+		fmt.Fprintf(&t.buf, " SP=%02X", after.sp)
+	}
+	t.buf.Truncate(len(bytes.TrimRight(t.buf.Bytes(), " ")))
+	t.buf.WriteByte('\n')
+}
+
+func (t *Tracer) formatBinary(cycle uint64, pc, op uint16, before, after registers) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], cycle)])
+	binary.Write(&t.buf, binary.BigEndian, pc)
+	binary.Write(&t.buf, binary.BigEndian, op)
+
+	mask := uint32(0)
+	values := []byte{}
+	for i := range after.v {
+		if before.v[i] != after.v[i] {
+			mask |= 1 << uint(i)
+			values = append(values, after.v[i])
+		}
+	}
+	if before.i != after.i {
+		mask |= 1 << 16
+		values = append(values, byte(after.i>>8), byte(after.i))
+	}
+	if before.dt != after.dt {
+		mask |= 1 << 17
+		values = append(values, after.dt)
+	}
+	if before.st != after.st {
+		mask |= 1 << 18
+		values = append(values, after.st)
+	}
+	if before.sp != after.sp {
+		mask |= 1 << 19
+		values = append(values, after.sp)
+	}
+	binary.Write(&t.buf, binary.BigEndian, mask)
+	t.buf.Write(values)
+}
+
+func (t *Tracer) Close() error {
+	if err := t.w.Flush(); err != nil && t.err == nil {
+		t.err = err
+	}
+	if err := t.f.Close(); err != nil && t.err == nil {
+		t.err = err
+	}
+	return t.err
+}
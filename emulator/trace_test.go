@@ -0,0 +1,85 @@
+package emulator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func traceROM(t *testing.T, rom []byte, steps int, setup func(tr *Tracer), format string) string {
+	path := filepath.Join(t.TempDir(), "trace")
+	tr, err := NewTracer(path, format)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if setup != nil {
+		setup(tr)
+	}
+
+	c := NewChip8(rom, QuirkPresets["default"])
+	c.tracer = tr
+	for i := 0; i < steps; i++ {
+		c.step()
+	}
+	assert.NoError(t, tr.Close())
+	return path
+}
+
+func readLines(t *testing.T, path string) []string {
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+func TestTraceText(t *testing.T) {
+	path := traceROM(t, quirksROM, 4, nil, TraceText)
+	assert.Equal(t, []string{
+		"1 200 6F05 LD   VF,#05    VF=05",
+		"2 202 6101 LD   V1,#01    V1=01",
+		"3 204 8011 OR   V0,V1     V0=01",
+		"4 206 85F0 LD   V5,VF     V5=05",
+	}, readLines(t, path))
+}
+
+func TestTraceRange(t *testing.T) {
+	path := traceROM(t, quirksROM, 4, func(tr *Tracer) {
+		tr.From, tr.To = 0x202, 0x204
+	}, TraceText)
+	lines := readLines(t, path)
+	assert.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "2 202 6101"))
+}
+
+func TestTraceRotate(t *testing.T) {
+	path := traceROM(t, quirksROM, 4, func(tr *Tracer) {
+		tr.Limit = 70
+	}, TraceText)
+	assert.Equal(t, "3 204 8011 OR   V0,V1     V0=01", readLines(t, path)[0])
+	assert.Equal(t, "1 200 6F05 LD   VF,#05    VF=05", readLines(t, path+".1")[0])
+	assert.Len(t, readLines(t, path+".1"), 2)
+}
+
+func TestTraceBinary(t *testing.T) {
+	path := traceROM(t, quirksROM, 2, nil, TraceBinary)
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		'C', '8', 'T', 'R',
+		1, 0x02, 0x00, 0x6F, 0x05, 0x00, 0x00, 0x80, 0x00, 0x05,
+		2, 0x02, 0x02, 0x61, 0x01, 0x00, 0x00, 0x00, 0x02, 0x01,
+	}, b)
+}
+
+func TestTraceSymbols(t *testing.T) {
+	symbols, _ := ParseSymbols(strings.NewReader("vf_reset 0x202\n"))
+	path := traceROM(t, quirksROM, 2, func(tr *Tracer) {
+		tr.Symbols = symbols
+	}, TraceText)
+	assert.Equal(t, []string{
+		"1 200 6F05 LD   VF,#05    VF=05",
+		"2 202 6101 vf_reset: LD   V1,#01    V1=01",
+	}, readLines(t, path))
+}
@@ -0,0 +1,268 @@
+package emulator
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TraceRecord is one executed instruction read from a trace.
+type TraceRecord struct {
+	Line   int
+	PC     uint16
+	Opcode uint16
+	Regs   map[string]uint16 // registers reported on this line
+	Text   string
+}
+
+// TraceReader reads the binary format written by Tracer as well as text
+// traces in the common "[cycle] PC opcode ... registers" layout, e.g.
+//
+//	12 2D8 F265 LD   V2,[I]     V0=01 V1=02 I=02F5
+//	PC:02D8 OP:F265 V0:01 V1:02 I:02F5
+//
+// Lines that do not contain a PC and an opcode are skipped.
+type TraceReader struct {
+	r      *bufio.Reader
+	binary bool
+	line   int
+}
+
+var (
+	traceRegister = regexp.MustCompile(`^(?i)(V[0-9A-F]|I|DT|ST|SP|PC|OP)[:=]([0-9A-F]+)$`)
+	traceHex      = regexp.MustCompile(`^(?i)[0-9A-F]{3,4}$`)
+	traceOpcode   = regexp.MustCompile(`^(?i)[0-9A-F]{4}$`)
+	traceDecimal  = regexp.MustCompile(`^[0-9]+$`)
+)
+
+func NewTraceReader(r io.Reader) *TraceReader {
+	t := &TraceReader{r: bufio.NewReader(r)}
+	if magic, err := t.r.Peek(len(traceMagic)); err == nil && string(magic) == traceMagic {
+		t.r.Discard(len(traceMagic))
+		t.binary = true
+	}
+	return t
+}
+
+// Next returns the next record or io.EOF.
+func (t *TraceReader) Next() (*TraceRecord, error) {
+	if t.binary {
+		return t.nextBinary()
+	}
+	for {
+		s, err := t.r.ReadString('\n')
+		if s == "" && err != nil {
+			return nil, err
+		}
+		t.line++
+		if rec := parseTraceLine(strings.TrimSpace(s)); rec != nil {
+			rec.Line = t.line
+			return rec, nil
+		}
+	}
+}
+
+func parseTraceLine(s string) *TraceRecord {
+	rec := &TraceRecord{Regs: map[string]uint16{}, Text: s}
+	fields := strings.Fields(s)
+	hasPC, hasOp := false, false
+	for _, f := range fields {
+		m := traceRegister.FindStringSubmatch(f)
+		if m == nil {
+			continue
+		}
+		v, _ := strconv.ParseUint(m[2], 16, 16)
+		switch name := strings.ToUpper(m[1]); name {
+		case "PC":
+			rec.PC, hasPC = uint16(v), true
+		case "OP":
+			rec.Opcode, hasOp = uint16(v), true
+		default:
+			rec.Regs[name] = uint16(v)
+		}
+	}
+	if hasPC && hasOp {
+		return rec
+	}
+
+	// positional layout: an optional decimal cycle, then pc and opcode
+	for _, k := range []int{1, 0} {
+		if k == 1 && (len(fields) < 3 || !traceDecimal.MatchString(fields[0])) {
+			continue
+		}
+		if len(fields) < k+2 || !traceHex.MatchString(fields[k]) || !traceOpcode.MatchString(fields[k+1]) {
+			continue
+		}
+		pc, _ := strconv.ParseUint(fields[k], 16, 16)
+		op, _ := strconv.ParseUint(fields[k+1], 16, 16)
+		rec.PC, rec.Opcode = uint16(pc), uint16(op)
+		return rec
+	}
+	return nil
+}
+
+func (t *TraceReader) nextBinary() (*TraceRecord, error) {
+	cycle, err := binary.ReadUvarint(t.r)
+	if err != nil {
+		return nil, err
+	}
+	var head struct {
+		PC, Opcode uint16
+		Mask       uint32
+	}
+	if err := binary.Read(t.r, binary.BigEndian, &head); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	t.line++
+
+	rec := &TraceRecord{Line: t.line, PC: head.PC, Opcode: head.Opcode, Regs: map[string]uint16{}}
+	names := []string{}
+	for i := uint(0); i < 20; i++ {
+		if head.Mask&(1<<i) == 0 {
+			continue
+		}
+		name := map[uint]string{16: "I", 17: "DT", 18: "ST", 19: "SP"}[i]
+		if i < 16 {
+			name = fmt.Sprintf("V%X", i)
+		}
+		var v uint16
+		if name == "I" {
+			err = binary.Read(t.r, binary.BigEndian, &v)
+		} else {
+			var b uint8
+			b, err = t.r.ReadByte()
+			v = uint16(b)
+		}
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		rec.Regs[name] = v
+		names = append(names, fmt.Sprintf("%s=%02X", name, v))
+	}
+	rec.Text = strings.TrimSpace(fmt.Sprintf("%d %03X %04X %s", cycle, rec.PC, rec.Opcode, strings.Join(names, " ")))
+	return rec, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// TraceDiff walks two traces instruction by instruction and writes the first
+// divergent instruction with context records around it to w. Register values
+// carry over between records so traces listing only changed registers can be
+// compared. Only registers both traces have logged are compared, unless strict
+// is set, which makes a register known to one trace but not the other a
+// difference. It reports whether the traces diverged.
+func TraceDiff(w io.Writer, a, b io.Reader, nameA, nameB string, context int, strict bool) (bool, error) {
+	ra, rb := NewTraceReader(a), NewTraceReader(b)
+	stateA, stateB := map[string]uint16{}, map[string]uint16{}
+	history := [][2]*TraceRecord{}
+
+	for n := 1; ; n++ {
+		recA, errA := ra.Next()
+		recB, errB := rb.Next()
+		if errA != nil && errA != io.EOF {
+			return false, fmt.Errorf("%s: %v", nameA, errA)
+		}
+		if errB != nil && errB != io.EOF {
+			return false, fmt.Errorf("%s: %v", nameB, errB)
+		}
+		if recA == nil && recB == nil {
+			fmt.Fprintf(w, "traces match (%d instructions)\n", n-1)
+			return false, nil
+		}
+
+		reason := ""
+		switch {
+		case recA == nil:
+			reason = fmt.Sprintf("%s ended", nameA)
+		case recB == nil:
+			reason = fmt.Sprintf("%s ended", nameB)
+		default:
+			for k, v := range recA.Regs {
+				stateA[k] = v
+			}
+			for k, v := range recB.Regs {
+				stateB[k] = v
+			}
+			reason = compareTraceRecords(recA, recB, stateA, stateB, strict)
+		}
+
+		if reason == "" {
+			history = append(history, [2]*TraceRecord{recA, recB})
+			if len(history) > context {
+				history = history[1:]
+			}
+			continue
+		}
+
+		fmt.Fprintf(w, "first divergence at instruction %d: %s\n", n, reason)
+		writeTraceContext(w, nameA, ra, 0, history, recA, context)
+		writeTraceContext(w, nameB, rb, 1, history, recB, context)
+		return true, nil
+	}
+}
+
+func compareTraceRecords(a, b *TraceRecord, stateA, stateB map[string]uint16, strict bool) string {
+	if a.PC != b.PC {
+		return fmt.Sprintf("PC %03X != %03X", a.PC, b.PC)
+	}
+	if a.Opcode != b.Opcode {
+		return fmt.Sprintf("opcode %04X != %04X", a.Opcode, b.Opcode)
+	}
+
+	names := map[string]bool{}
+	for k := range stateA {
+		names[k] = true
+	}
+	for k := range stateB {
+		names[k] = true
+	}
+	diffs := []string{}
+	for k := range names {
+		va, okA := stateA[k]
+		vb, okB := stateB[k]
+		switch {
+		case !strict && (!okA || !okB):
+		case !okA:
+			diffs = append(diffs, fmt.Sprintf("%s missing != %02X", k, vb))
+		case !okB:
+			diffs = append(diffs, fmt.Sprintf("%s %02X != missing", k, va))
+		case va != vb:
+			diffs = append(diffs, fmt.Sprintf("%s %02X != %02X", k, va, vb))
+		}
+	}
+	sort.Strings(diffs)
+	return strings.Join(diffs, ", ")
+}
+
+func writeTraceContext(w io.Writer, name string, r *TraceReader, side int, history [][2]*TraceRecord, rec *TraceRecord, context int) {
+	fmt.Fprintf(w, "--- %s\n", name)
+	for _, h := range history {
+		fmt.Fprintf(w, "    %5d  %s\n", h[side].Line, h[side].Text)
+	}
+	if rec == nil {
+		fmt.Fprintf(w, ">   (end of trace)\n")
+		return
+	}
+	fmt.Fprintf(w, ">   %5d  %s\n", rec.Line, rec.Text)
+	for i := 0; i < context; i++ {
+		next, err := r.Next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Fprintf(w, "    %v\n", err)
+			}
+			return
+		}
+		fmt.Fprintf(w, "    %5d  %s\n", next.Line, next.Text)
+	}
+}
@@ -0,0 +1,100 @@
+package emulator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTrace(t *testing.T, q Quirks, format string, steps int) string {
+	path := filepath.Join(t.TempDir(), "trace")
+	tr, err := NewTracer(path, format)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	c := NewChip8(quirksROM, q)
+	c.tracer = tr
+	for i := 0; i < steps; i++ {
+		c.step()
+	}
+	assert.NoError(t, tr.Close())
+	return path
+}
+
+func diffFiles(t *testing.T, a, b string, context int, strict bool) (bool, string) {
+	fa, err := os.Open(a)
+	assert.NoError(t, err)
+	defer fa.Close()
+	fb, err := os.Open(b)
+	assert.NoError(t, err)
+	defer fb.Close()
+
+	var out strings.Builder
+	diverged, err := TraceDiff(&out, fa, fb, "a", "b", context, strict)
+	assert.NoError(t, err)
+	return diverged, out.String()
+}
+
+func TestTraceDiffMatch(t *testing.T) {
+	a := writeTrace(t, QuirkPresets["vip"], TraceText, 30)
+	b := writeTrace(t, QuirkPresets["vip"], TraceBinary, 30)
+	diverged, out := diffFiles(t, a, b, 2, false)
+	assert.False(t, diverged)
+	assert.Equal(t, "traces match (30 instructions)\n", out)
+}
+
+func TestTraceDiffDivergence(t *testing.T) {
+	a := writeTrace(t, QuirkPresets["default"], TraceText, 30)
+	b := writeTrace(t, QuirkPresets["vip"], TraceText, 30)
+	diverged, out := diffFiles(t, a, b, 1, false)
+	assert.True(t, diverged)
+	assert.Equal(t, `first divergence at instruction 3: VF 05 != 00
+--- a
+        2  2 202 6101 LD   V1,#01    V1=01
+>       3  3 204 8011 OR   V0,V1     V0=01
+        4  4 206 85F0 LD   V5,VF     V5=05
+--- b
+        2  2 202 6101 LD   V1,#01    V1=01
+>       3  3 204 8011 OR   V0,V1     V0=01 VF=00
+        4  4 206 85F0 LD   V5,VF
+`, out)
+}
+
+func TestTraceDiffRegisters(t *testing.T) {
+	ours := writeTrace(t, QuirkPresets["vip"], TraceText, 3)
+	// another emulator dumping every register it knows
+	dir := t.TempDir()
+	full := filepath.Join(dir, "full")
+	os.WriteFile(full, []byte("PC:0200 OP:6F05 V0:00 V1:00 VF:05 I:0000\n"+
+		"PC:0202 OP:6101 V0:00 V1:01 VF:05 I:0000\n"+
+		"PC:0204 OP:8011 V0:01 V1:01 VF:00 I:0000\n"), 0644)
+
+	diverged, out := diffFiles(t, ours, full, 0, false)
+	assert.False(t, diverged)
+	assert.Equal(t, "traces match (3 instructions)\n", out)
+
+	diverged, out = diffFiles(t, ours, full, 0, true)
+	assert.True(t, diverged)
+	assert.Contains(t, out, "first divergence at instruction 1: ")
+	assert.Contains(t, out, "I missing != 00")
+}
+
+func TestParseTraceLine(t *testing.T) {
+	rec := parseTraceLine("PC:02D8 OP:F265 V0:01 V1:02 I:02F5")
+	if assert.NotNil(t, rec) {
+		assert.Equal(t, uint16(0x2d8), rec.PC)
+		assert.Equal(t, uint16(0xf265), rec.Opcode)
+		assert.Equal(t, map[string]uint16{"V0": 1, "V1": 2, "I": 0x2f5}, rec.Regs)
+	}
+
+	rec = parseTraceLine("200 7001 ADD  V0,#01")
+	if assert.NotNil(t, rec) {
+		assert.Equal(t, uint16(0x200), rec.PC)
+		assert.Equal(t, uint16(0x7001), rec.Opcode)
+	}
+
+	assert.Nil(t, parseTraceLine("# comment"))
+}
@@ -0,0 +1,124 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	WAVSampleRate = 44100
+	BeepFrequency = 440
+)
+
+// wavWriter writes 16-bit mono PCM. The sizes in the header are brought
+// up to date every second of sound and on Close, so a file cut short by a
+// crash still plays up to about there.
+type wavWriter struct {
+	f       *os.File
+	rate    int
+	samples uint32
+	synced  uint32 // samples in the header
+}
+
+func createWAV(path string, rate int) (*wavWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &wavWriter{f: f, rate: rate}
+	if err := writeWAVHeader(f, rate, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// writeWAVHeader writes the header of a 16-bit mono WAV file of samples
+// samples.
+func writeWAVHeader(w io.Writer, rate int, samples uint32) error {
+	size := 2 * samples
+	h := []interface{}{
+		[4]byte{'R', 'I', 'F', 'F'}, 36 + size, [4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '}, uint32(16),
+		uint16(1), uint16(1), uint32(rate), uint32(2 * rate), uint16(2), uint16(16),
+		[4]byte{'d', 'a', 't', 'a'}, size,
+	}
+	for _, v := range h {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *wavWriter) Write(samples []int16) error {
+	if err := binary.Write(w.f, binary.LittleEndian, samples); err != nil {
+		return err
+	}
+	w.samples += uint32(len(samples))
+	if w.samples-w.synced >= uint32(w.rate) {
+		return w.sync()
+	}
+	return nil
+}
+
+// sync writes the RIFF and data sizes of the samples written so far.
+func (w *wavWriter) sync() error {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], 36+2*w.samples)
+	if _, err := w.f.WriteAt(b[:], 4); err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(b[:], 2*w.samples)
+	if _, err := w.f.WriteAt(b[:], 40); err != nil {
+		return err
+	}
+	w.synced = w.samples
+	return nil
+}
+
+func (w *wavWriter) Close() error {
+	if err := w.sync(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// wavAudio renders the buzzer one frame per Tone, in emulated time, and
+// streams it to a WAV file.
+type wavAudio struct {
+	w     *wavWriter
+	synth *Synth
+	err   error
+}
+
+// NewWAVAudio renders the buzzer at the sample rate of o to path. The file
+// is complete when Run returns.
+func NewWAVAudio(path string, o SynthOptions) (Audio, error) {
+	if o.SampleRate <= 0 {
+		return nil, fmt.Errorf("invalid sample rate %d", o.SampleRate)
+	}
+	w, err := createWAV(path, o.SampleRate)
+	if err != nil {
+		return nil, err
+	}
+	return &wavAudio{w: w, synth: NewSynth(o)}, nil
+}
+
+func (a *wavAudio) Tone(on bool) {
+	if a.err == nil {
+		a.err = a.w.Write(a.synth.frame(on))
+	}
+}
+
+// Close completes the file and returns the first error writing it.
+func (a *wavAudio) Close() error {
+	err := a.w.Close()
+	if a.err != nil {
+		err = a.err
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// PCMAudio is an Audio that keeps the buzzer as samples, one frame per
+// Tone, to check them in tests.
+type PCMAudio struct {
+	synth   *Synth
+	samples []int16
+}
+
+func NewPCMAudio(o SynthOptions) *PCMAudio {
+	return &PCMAudio{synth: NewSynth(o)}
+}
+
+func (a *PCMAudio) Tone(on bool) {
+	a.samples = append(a.samples, a.synth.frame(on)...)
+}
+
+// Samples returns the samples rendered so far.
+func (a *PCMAudio) Samples() []int16 {
+	return a.samples
+}
+
+// runPCM runs b for frames frames under q and returns the beep.
+func runPCM(b []byte, q Quirks, frames int) []int16 {
+	a := NewPCMAudio(DefaultSynth)
+	e := NewEmulator(b, false, q, Frontend{nullVideo{}, a, nullInput{}})
+	e.SetMaxFrames(frames)
+	e.Run()
+	return a.Samples()
+}
+
+// beepFrames counts the frames with sound in samples of DefaultSynth.
+func beepFrames(samples []int16) int {
+	n := 0
+	per := DefaultSynth.SampleRate / VBlankFrequency
+	for i := 0; i+per <= len(samples); i += per {
+		for _, s := range samples[i : i+per] {
+			if s != 0 {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+func TestPCMAudioQuirks(t *testing.T) {
+	// V1=6, V0=0, SHR V1,V0, ST=V1, loop: JP loop
+	rom := assemble(0x6106, 0x6000, 0x8106, 0xF118, 0x1208)
+
+	shift := runPCM(rom, QuirkPresets["default"], 10)
+	assert.Len(t, shift, 10*WAVSampleRate/VBlankFrequency)
+	// ST=3 sounds for two frames like toneROM, the fade out reaches into
+	// the third
+	assert.Equal(t, 3, beepFrames(shift))
+
+	vip := runPCM(rom, QuirkPresets["vip"], 10)
+	assert.Equal(t, make([]int16, len(vip)), vip)
+	assert.Equal(t, runPCM(rom, QuirkPresets["schip"], 10), shift)
+}
+
+func TestWAVAudio(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beep.wav")
+	o := DefaultSynth
+	o.SampleRate = 22050
+	a, err := NewWAVAudio(path, o)
+	assert.NoError(t, err)
+	e := NewEmulator(toneROM, false, QuirkPresets["default"], Frontend{nullVideo{}, a, nullInput{}})
+	e.SetMaxFrames(120)
+	e.Run()
+
+	wav, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	// 367.5 samples a frame, two seconds exactly
+	assert.Equal(t, 44+2*2*22050, len(wav))
+	assert.Equal(t, uint32(22050), binary.LittleEndian.Uint32(wav[24:]))
+	assert.Equal(t, uint32(2*2*22050), binary.LittleEndian.Uint32(wav[40:]))
+}
+
+func TestWAVHeaderSync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beep.wav")
+	w, err := createWAV(path, 100)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Close()
+	size := func() uint32 {
+		wav, _ := os.ReadFile(path)
+		return binary.LittleEndian.Uint32(wav[40:])
+	}
+	assert.NoError(t, w.Write(make([]int16, 60)))
+	assert.Equal(t, uint32(0), size())
+	// a second of sound, as if the process died now
+	assert.NoError(t, w.Write(make([]int16, 60)))
+	assert.Equal(t, uint32(2*120), size())
+}
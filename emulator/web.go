@@ -0,0 +1,338 @@
+package emulator
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+//go:embed web/index.html
+var webPage []byte
+
+// webMessage is sent to the page. A full message lists every lit pixel in On
+// and replaces the screen, otherwise On and Off are the pixels that changed
+// since the last frame.
+type webMessage struct {
+	Full bool          `json:"full,omitempty"`
+	On   []int         `json:"on,omitempty"`
+	Off  []int         `json:"off,omitempty"`
+	Regs *webRegisters `json:"regs,omitempty"`
+	Tone *bool         `json:"tone,omitempty"`
+	// Palette colors the display values 0-3 as CSS colors, see
+	// Palette.Colors. The page redraws the screen when it changes.
+	Palette *[4]string `json:"palette,omitempty"`
+}
+
+type webRegisters struct {
+	Mode string    `json:"mode"`
+	PC   uint16    `json:"pc"`
+	I    uint16    `json:"i"`
+	SP   uint8     `json:"sp"`
+	DT   uint8     `json:"dt"`
+	ST   uint8     `json:"st"`
+	V    [16]uint8 `json:"v"`
+	Op   string    `json:"op"`
+}
+
+// webInput is received from the page: a chip8 key going down or up, or a
+// hotkey by name.
+type webInput struct {
+	Key    *uint8 `json:"key"`
+	Down   bool   `json:"down"`
+	Hotkey string `json:"hotkey"`
+}
+
+var webHotkeys = map[string]Hotkey{
+	"step":     HotkeyStep,
+	"resume":   HotkeyResume,
+	"reset":    HotkeyReset,
+	"stepover": HotkeyStepOver,
+	"stepout":  HotkeyStepOut,
+	"frame":    HotkeyRunFrame,
+	"palette":  HotkeyPalette,
+	"quit":     HotkeyQuit,
+}
+
+// webFrontend runs the machine server-side and streams the display to the
+// pages connected over WebSocket.
+type webFrontend struct {
+	ln     net.Listener
+	srv    *http.Server
+	events chan Event
+	ticker *time.Ticker
+
+	mu      sync.Mutex
+	clients map[*wsConn]bool // false until the client got a full frame
+	disp    [Chip8DisplayW * Chip8DisplayH]uint8
+	regs    webRegisters
+	tone    bool
+	sent    bool // tone the clients know of
+	palette [4]string
+}
+
+// NewWebFrontend serves the emulator page on addr, e.g. ":8080", which is
+// 127.0.0.1:8080. Anyone who can open the page can play, so addresses other
+// machines can reach are refused unless public is set.
+func NewWebFrontend(addr string, public bool) (Frontend, error) {
+	f, err := newWebFrontend(addr, public)
+	if err != nil {
+		return Frontend{}, err
+	}
+	f.ticker = time.NewTicker(time.Second / VBlankFrequency)
+	fmt.Fprintln(os.Stderr, "serving", f.pageURL())
+	return Frontend{Video: f, Audio: f, Input: f}, nil
+}
+
+func newWebFrontend(addr string, public bool) (*webFrontend, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if !public {
+		if host == "" {
+			host = "127.0.0.1"
+		}
+		if !isLoopback(host) {
+			return nil, fmt.Errorf("%s is not a loopback address and would let other machines use the emulator", host)
+		}
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	f := &webFrontend{ln: ln, events: make(chan Event, 256), clients: map[*wsConn]bool{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", f.servePage)
+	mux.HandleFunc("/ws", f.serveWebSocket)
+	var h http.Handler = mux
+	if !public {
+		h = loopbackHost(mux)
+	}
+	f.srv = &http.Server{Handler: h}
+	go f.srv.Serve(ln)
+	return f, nil
+}
+
+func (f *webFrontend) pageURL() string {
+	addr := f.ln.Addr().(*net.TCPAddr)
+	if addr.IP.IsUnspecified() {
+		return fmt.Sprintf("http://localhost:%d/", addr.Port)
+	}
+	return fmt.Sprintf("http://%s/", addr)
+}
+
+func (f *webFrontend) servePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(webPage)
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// loopbackHost refuses requests for other host names than loopback ones. A
+// site whose name was rebound to 127.0.0.1 would otherwise be same-origin
+// with the emulator.
+func loopbackHost(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if name, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = name
+		}
+		if !isLoopback(host) {
+			http.Error(w, "unknown host", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// sameOrigin keeps other sites open in the browser from connecting to the
+// emulator, and clients that send no Origin, which browsers always do for
+// WebSockets, from connecting at all.
+func sameOrigin(r *http.Request) bool {
+	u, err := url.Parse(r.Header.Get("Origin"))
+	return err == nil && u.Host != "" && u.Host == r.Host
+}
+
+func (f *webFrontend) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request", http.StatusForbidden)
+		return
+	}
+	c, err := wsUpgrade(w, r)
+	if err != nil {
+		return
+	}
+	f.mu.Lock()
+	f.clients[c] = false
+	f.mu.Unlock()
+
+	var pressed [16]bool
+	defer func() {
+		f.mu.Lock()
+		delete(f.clients, c)
+		f.mu.Unlock()
+		c.Close()
+		// keys held in a closed page would stay down forever
+		for i, p := range pressed {
+			if p {
+				f.send(Event{Kind: EventKeyUp, Key: uint8(i)})
+			}
+		}
+	}()
+
+	for {
+		msg, err := c.ReadText()
+		if err != nil {
+			return
+		}
+		var in webInput
+		if err := json.Unmarshal(msg, &in); err != nil {
+			continue
+		}
+		if in.Key != nil && *in.Key < 16 {
+			pressed[*in.Key] = in.Down
+			if in.Down {
+				f.send(Event{Kind: EventKeyDown, Key: *in.Key})
+			} else {
+				f.send(Event{Kind: EventKeyUp, Key: *in.Key})
+			}
+		} else if h, ok := webHotkeys[in.Hotkey]; ok {
+			f.send(Event{Kind: EventHotkey, Hotkey: h})
+		}
+	}
+}
+
+// send queues ev for the next Poll, dropping it if the emulator lags behind.
+func (f *webFrontend) send(ev Event) {
+	select {
+	case f.events <- ev:
+	default:
+	}
+}
+
+func (f *webFrontend) Poll(e *Emulator) []Event {
+	var events []Event
+	for {
+		select {
+		case ev := <-f.events:
+			events = append(events, ev)
+		default:
+			return events
+		}
+	}
+}
+
+func (f *webFrontend) Tone(on bool) {
+	f.mu.Lock()
+	f.tone = on
+	f.mu.Unlock()
+}
+
+func webRegistersOf(e *Emulator) webRegisters {
+	c := e.chip8
+	mode := "RUN"
+	if e.stepMode {
+		mode = "STEP"
+	}
+	return webRegisters{
+		Mode: mode, PC: c.pc, I: c.i, SP: c.sp, DT: c.dt, ST: c.st, V: c.v,
+		Op: formatInstruction(c.pc, c.opcodeAt(c.pc), e.symbols, c.quirks),
+	}
+}
+
+func webPalette(p Palette) [4]string {
+	var css [4]string
+	for i, c := range p.Colors() {
+		css[i] = fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return css
+}
+
+// delta returns the message that brings a page showing prev up to date.
+func delta(prev, disp *[Chip8DisplayW * Chip8DisplayH]uint8) webMessage {
+	var m webMessage
+	for i, p := range disp {
+		if p != prev[i] {
+			if p != 0 {
+				m.On = append(m.On, i)
+			} else {
+				m.Off = append(m.Off, i)
+			}
+		}
+	}
+	return m
+}
+
+func (f *webFrontend) Present(e *Emulator) {
+	f.mu.Lock()
+	regs := webRegistersOf(e)
+	tone := f.tone
+	update := delta(&f.disp, &e.chip8.disp)
+	if regs != f.regs {
+		update.Regs = &regs
+	}
+	if tone != f.sent {
+		update.Tone = &tone
+	}
+	palette := webPalette(e.Palette())
+	if palette != f.palette {
+		update.Palette = &palette
+	}
+	var blank [Chip8DisplayW * Chip8DisplayH]uint8
+	full := delta(&blank, &e.chip8.disp)
+	full.Full, full.Regs, full.Tone, full.Palette = true, &regs, &tone, &palette
+	f.disp, f.regs, f.sent, f.palette = e.chip8.disp, regs, tone, palette
+
+	var failed []*wsConn
+	for c, synced := range f.clients {
+		m := update
+		if !synced {
+			m = full
+			f.clients[c] = true
+		} else if m.On == nil && m.Off == nil && m.Regs == nil && m.Tone == nil && m.Palette == nil {
+			continue
+		}
+		b, _ := json.Marshal(m)
+		if err := c.WriteText(b); err != nil {
+			failed = append(failed, c)
+		}
+	}
+	for _, c := range failed {
+		delete(f.clients, c)
+		c.Close()
+	}
+	f.mu.Unlock()
+
+	if f.ticker != nil {
+		<-f.ticker.C
+	}
+}
+
+func (f *webFrontend) Close() error {
+	if f.ticker != nil {
+		f.ticker.Stop()
+	}
+	// hijacked connections are no longer the server's
+	f.mu.Lock()
+	for c := range f.clients {
+		c.Close()
+	}
+	f.mu.Unlock()
+	return f.srv.Close()
+}
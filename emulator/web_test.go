@@ -0,0 +1,156 @@
+package emulator
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelta(t *testing.T) {
+	var prev, disp [Chip8DisplayW * Chip8DisplayH]uint8
+	prev[1], prev[2] = 1, 1
+	disp[2], disp[3] = 1, 1
+	m := delta(&prev, &disp)
+	assert.Equal(t, []int{3}, m.On)
+	assert.Equal(t, []int{1}, m.Off)
+	assert.Empty(t, delta(&disp, &disp).On)
+}
+
+// dialWeb connects to the frontend like a page from origin that asks for
+// host.
+func dialWeb(t *testing.T, f *webFrontend, host, origin string) (*wsConn, *http.Response) {
+	conn, err := net.Dial("tcp", f.ln.Addr().String())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: %s\r\nOrigin: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", host, origin)
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return &wsConn{conn: conn, r: r}, resp
+}
+
+func readWeb(t *testing.T, c *wsConn) webMessage {
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	b, err := c.ReadText()
+	assert.NoError(t, err)
+	var m webMessage
+	assert.NoError(t, json.Unmarshal(b, &m))
+	return m
+}
+
+// pollWeb polls until an event arrives from the connection's reader.
+func pollWeb(f *webFrontend, e *Emulator) []Event {
+	for i := 0; i < 100; i++ {
+		if events := f.Poll(e); events != nil {
+			return events
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+func TestWebFrontendAddress(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:0", "192.0.2.1:0", "example.com:0", "8080"} {
+		_, err := newWebFrontend(addr, false)
+		assert.Error(t, err, addr)
+	}
+	f, err := newWebFrontend("localhost:0", false)
+	if assert.NoError(t, err) {
+		f.Close()
+	}
+}
+
+func TestWebFrontend(t *testing.T) {
+	f, err := newWebFrontend(":0", false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+	e := NewEmulator(toneROM, false, QuirkPresets["default"], Frontend{f, f, f})
+
+	resp, err := http.Get(f.pageURL())
+	if assert.NoError(t, err) {
+		page, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, webPage, page)
+	}
+
+	host := f.ln.Addr().String()
+	assert.Equal(t, "127.0.0.1", f.ln.Addr().(*net.TCPAddr).IP.String())
+	_, resp = dialWeb(t, f, host, "http://evil.example")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	_, resp = dialWeb(t, f, host, "")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	// a name rebound to 127.0.0.1
+	_, resp = dialWeb(t, f, "evil.example:80", "http://evil.example:80")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	req, _ := http.NewRequest("GET", f.pageURL(), nil)
+	req.Host = "evil.example"
+	if resp, err := http.DefaultClient.Do(req); assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	}
+
+	c, resp := dialWeb(t, f, host, "http://"+host)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	defer c.Close()
+
+	// wait for the server to register the client
+	for i := 0; i < 100; i++ {
+		f.mu.Lock()
+		n := len(f.clients)
+		f.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	e.chip8.disp[5] = 1
+	f.Tone(true)
+	f.Present(e)
+	m := readWeb(t, c)
+	assert.True(t, m.Full)
+	assert.Equal(t, []int{5}, m.On)
+	assert.Equal(t, "RUN", m.Regs.Mode)
+	assert.Equal(t, uint16(0x200), m.Regs.PC)
+	assert.True(t, *m.Tone)
+	assert.Equal(t, [4]string{"#000000", "#00ff00", "#008000", "#80ff80"}, *m.Palette)
+
+	e.chip8.disp[5], e.chip8.disp[6] = 0, 1
+	f.Present(e)
+	m = readWeb(t, c)
+	assert.False(t, m.Full)
+	assert.Equal(t, []int{6}, m.On)
+	assert.Equal(t, []int{5}, m.Off)
+	assert.Nil(t, m.Regs)
+	assert.Nil(t, m.Tone)
+	assert.Nil(t, m.Palette)
+
+	e.SetPalette(Palette{Foreground: color.RGBA{255, 255, 255, 255}})
+	f.Present(e)
+	m = readWeb(t, c)
+	assert.Equal(t, "#ffffff", m.Palette[1])
+
+	c.WriteText([]byte(`{"key":10,"down":true}`))
+	assert.Equal(t, []Event{{Kind: EventKeyDown, Key: 0xa}}, pollWeb(f, e))
+	c.WriteText([]byte(`{"hotkey":"step"}`))
+	assert.Equal(t, []Event{{Kind: EventHotkey, Hotkey: HotkeyStep}}, pollWeb(f, e))
+	c.WriteText([]byte(`{"hotkey":"quit"}`))
+	assert.Equal(t, []Event{{Kind: EventHotkey, Hotkey: HotkeyQuit}}, pollWeb(f, e))
+
+	// closing the page releases its keys
+	c.Close()
+	assert.Equal(t, []Event{{Kind: EventKeyUp, Key: 0xa}}, pollWeb(f, e))
+}
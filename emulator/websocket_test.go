@@ -0,0 +1,56 @@
+package emulator
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebSocketAccept(t *testing.T) {
+	// the example of RFC 6455 section 1.3
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", wsAccept("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+func wsPipe() (*wsConn, *wsConn) {
+	a, b := net.Pipe()
+	return &wsConn{conn: a, r: bufio.NewReader(a)}, &wsConn{conn: b, r: bufio.NewReader(b)}
+}
+
+func TestWebSocketFrames(t *testing.T) {
+	a, b := wsPipe()
+	defer a.Close()
+	defer b.Close()
+
+	long := []byte(strings.Repeat("x", 300))
+	go func() {
+		a.WriteText([]byte("hello"))
+		a.WriteText(long)
+		a.writeFrame(wsClose, nil)
+	}()
+	msg, err := b.ReadText()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(msg))
+	msg, err = b.ReadText()
+	assert.NoError(t, err)
+	assert.Equal(t, long, msg)
+
+	// ReadText answers the close before it returns
+	go a.readFrame()
+	_, err = b.ReadText()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestWebSocketMaskedFrame(t *testing.T) {
+	// "Hello" masked, RFC 6455 section 5.7
+	frame := []byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58}
+	c := &wsConn{r: bufio.NewReader(bytes.NewReader(frame))}
+	op, payload, err := c.readFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, byte(wsText), op)
+	assert.Equal(t, "Hello", string(payload))
+}
@@ -0,0 +1,265 @@
+// Command libretro is the emulator as a libretro core:
+//
+//	go build -tags nosdl -buildmode=c-shared -o chip8_libretro.so ./libretro
+//
+// The nosdl tag keeps the SDL frontend of the emulator package, which the
+// core does not use, and libSDL2 out of the library.
+// The rom runs with the default quirks. The chip8 keys are on the keyboard
+// as in the SDL window, and the d-pad and face buttons of the first joypad
+// press 2/8/4/6 and 5, 0, 1, C.
+package main
+
+/*
+#include "libretro.h"
+
+static bool call_environment(retro_environment_t cb, unsigned cmd, void *data) {
+	return cb(cmd, data);
+}
+static void call_video_refresh(retro_video_refresh_t cb, const void *data, unsigned width, unsigned height, size_t pitch) {
+	cb(data, width, height, pitch);
+}
+static size_t call_audio_sample_batch(retro_audio_sample_batch_t cb, const int16_t *data, size_t frames) {
+	return cb(data, frames);
+}
+static void call_input_poll(retro_input_poll_t cb) {
+	cb();
+}
+static int16_t call_input_state(retro_input_state_t cb, unsigned port, unsigned device, unsigned index, unsigned id) {
+	return cb(port, device, index, id);
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unsafe"
+
+	e "github.com/tuboc/chip8/emulator"
+)
+
+const (
+	sampleRate      = 44100
+	samplesPerFrame = sampleRate / e.VBlankFrequency
+	// stateSize bounds a gob encoded save state, which varies in size, plus
+	// its length
+	stateSize = 16 << 10
+)
+
+// keyboard is the SDL layout by RETROK code, which is ASCII for these keys.
+var keyboard = map[C.unsigned]uint8{
+	'4': 0x1, '5': 0x2, '6': 0x3, '7': 0xc,
+	'r': 0x4, 't': 0x5, 'y': 0x6, 'u': 0xd,
+	'f': 0x7, 'g': 0x8, 'h': 0x9, 'j': 0xe,
+	'v': 0xa, 'b': 0x0, 'n': 0xb, 'm': 0xf,
+}
+
+var joypad = map[C.unsigned]uint8{
+	C.RETRO_DEVICE_ID_JOYPAD_UP:    0x2,
+	C.RETRO_DEVICE_ID_JOYPAD_DOWN:  0x8,
+	C.RETRO_DEVICE_ID_JOYPAD_LEFT:  0x4,
+	C.RETRO_DEVICE_ID_JOYPAD_RIGHT: 0x6,
+	C.RETRO_DEVICE_ID_JOYPAD_A:     0x5,
+	C.RETRO_DEVICE_ID_JOYPAD_B:     0x0,
+	C.RETRO_DEVICE_ID_JOYPAD_X:     0x1,
+	C.RETRO_DEVICE_ID_JOYPAD_Y:     0xc,
+}
+
+var (
+	environment  C.retro_environment_t
+	videoRefresh C.retro_video_refresh_t
+	audioBatch   C.retro_audio_sample_batch_t
+	inputPoll    C.retro_input_poll_t
+	inputState   C.retro_input_state_t
+
+	libraryName     = C.CString("Chip-8")
+	libraryVersion  = C.CString("1.0")
+	validExtensions = C.CString("ch8|c8|rom")
+
+	rom   []byte
+	chip8 *e.Chip8
+	video [e.Chip8DisplayW * e.Chip8DisplayH]uint32
+	audio [2 * samplesPerFrame]int16
+	synth *e.Synth
+)
+
+func main() {}
+
+//export retro_api_version
+func retro_api_version() C.unsigned {
+	return C.RETRO_API_VERSION
+}
+
+//export retro_set_environment
+func retro_set_environment(cb C.retro_environment_t) {
+	environment = cb
+	noGame := C.bool(false)
+	C.call_environment(cb, C.RETRO_ENVIRONMENT_SET_SUPPORT_NO_GAME, unsafe.Pointer(&noGame))
+}
+
+//export retro_set_video_refresh
+func retro_set_video_refresh(cb C.retro_video_refresh_t) { videoRefresh = cb }
+
+//export retro_set_audio_sample
+func retro_set_audio_sample(cb C.retro_audio_sample_t) {}
+
+//export retro_set_audio_sample_batch
+func retro_set_audio_sample_batch(cb C.retro_audio_sample_batch_t) { audioBatch = cb }
+
+//export retro_set_input_poll
+func retro_set_input_poll(cb C.retro_input_poll_t) { inputPoll = cb }
+
+//export retro_set_input_state
+func retro_set_input_state(cb C.retro_input_state_t) { inputState = cb }
+
+//export retro_init
+func retro_init() {}
+
+//export retro_deinit
+func retro_deinit() {}
+
+//export retro_get_system_info
+func retro_get_system_info(info *C.struct_retro_system_info) {
+	info.library_name = libraryName
+	info.library_version = libraryVersion
+	info.valid_extensions = validExtensions
+	info.need_fullpath = false
+	info.block_extract = false
+}
+
+//export retro_get_system_av_info
+func retro_get_system_av_info(info *C.struct_retro_system_av_info) {
+	info.geometry.base_width = e.Chip8DisplayW
+	info.geometry.base_height = e.Chip8DisplayH
+	info.geometry.max_width = e.Chip8DisplayW
+	info.geometry.max_height = e.Chip8DisplayH
+	info.geometry.aspect_ratio = 2
+	info.timing.fps = e.VBlankFrequency
+	info.timing.sample_rate = sampleRate
+}
+
+//export retro_set_controller_port_device
+func retro_set_controller_port_device(port, device C.unsigned) {}
+
+//export retro_get_region
+func retro_get_region() C.unsigned {
+	return C.RETRO_REGION_NTSC
+}
+
+//export retro_load_game
+func retro_load_game(info *C.struct_retro_game_info) C.bool {
+	if info == nil || info.data == nil {
+		return false
+	}
+	format := C.enum_retro_pixel_format(C.RETRO_PIXEL_FORMAT_XRGB8888)
+	if !C.call_environment(environment, C.RETRO_ENVIRONMENT_SET_PIXEL_FORMAT, unsafe.Pointer(&format)) {
+		return false
+	}
+	rom = C.GoBytes(info.data, C.int(info.size))
+	retro_reset()
+	return true
+}
+
+//export retro_load_game_special
+func retro_load_game_special(gameType C.unsigned, info *C.struct_retro_game_info, n C.size_t) C.bool {
+	return false
+}
+
+//export retro_unload_game
+func retro_unload_game() {
+	chip8, rom = nil, nil
+}
+
+//export retro_reset
+func retro_reset() {
+	chip8 = e.NewChip8(rom, e.QuirkPresets["default"])
+	o := e.DefaultSynth
+	o.SampleRate = sampleRate
+	synth = e.NewSynth(o)
+}
+
+//export retro_run
+func retro_run() {
+	if chip8 == nil {
+		return
+	}
+	C.call_input_poll(inputPoll)
+	pressed := map[uint8]bool{}
+	for id, key := range keyboard {
+		pressed[key] = pressed[key] || C.call_input_state(inputState, 0, C.RETRO_DEVICE_KEYBOARD, 0, id) != 0
+	}
+	for id, key := range joypad {
+		pressed[key] = pressed[key] || C.call_input_state(inputState, 0, C.RETRO_DEVICE_JOYPAD, 0, id) != 0
+	}
+	for key, down := range pressed {
+		chip8.SetKey(key, down)
+	}
+
+	chip8.RunFrame()
+
+	colors := e.DefaultPalette.Colors()
+	for i, p := range chip8.Display() {
+		c := colors[p&3]
+		video[i] = uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B)
+	}
+	C.call_video_refresh(videoRefresh, unsafe.Pointer(&video[0]), e.Chip8DisplayW, e.Chip8DisplayH, 4*e.Chip8DisplayW)
+
+	var mono [samplesPerFrame]int16
+	synth.SetTone(chip8.Sound())
+	synth.ReadInt16(mono[:])
+	for i, s := range mono {
+		audio[2*i], audio[2*i+1] = s, s
+	}
+	C.call_audio_sample_batch(audioBatch, (*C.int16_t)(unsafe.Pointer(&audio[0])), samplesPerFrame)
+}
+
+//export retro_serialize_size
+func retro_serialize_size() C.size_t {
+	return stateSize
+}
+
+//export retro_serialize
+func retro_serialize(data unsafe.Pointer, size C.size_t) C.bool {
+	n := int(size)
+	if n > stateSize {
+		n = stateSize
+	}
+	var b bytes.Buffer
+	if chip8 == nil || chip8.WriteState(&b) != nil || b.Len()+4 > n {
+		return false
+	}
+	out := (*[stateSize]byte)(data)[:n:n]
+	binary.LittleEndian.PutUint32(out, uint32(b.Len()))
+	copy(out[4:], b.Bytes())
+	return true
+}
+
+//export retro_unserialize
+func retro_unserialize(data unsafe.Pointer, size C.size_t) C.bool {
+	if chip8 == nil || size < 4 {
+		return false
+	}
+	in := C.GoBytes(data, C.int(size))
+	n := binary.LittleEndian.Uint32(in)
+	if int(n) > len(in)-4 {
+		return false
+	}
+	return chip8.ReadState(bytes.NewReader(in[4:4+n])) == nil
+}
+
+//export retro_cheat_reset
+func retro_cheat_reset() {}
+
+//export retro_cheat_set
+func retro_cheat_set(index C.unsigned, enabled C.bool, code *C.char) {}
+
+//export retro_get_memory_data
+func retro_get_memory_data(id C.unsigned) unsafe.Pointer {
+	// Go memory must not be kept by C
+	return nil
+}
+
+//export retro_get_memory_size
+func retro_get_memory_size(id C.unsigned) C.size_t {
+	return 0
+}
@@ -0,0 +1,76 @@
+/* The part of the libretro API (https://github.com/libretro/libretro-common,
+ * include/libretro.h) this core uses: the types, callbacks and constants.
+ * The retro_* functions themselves are declared by cgo in _cgo_export.h. */
+#ifndef CHIP8_LIBRETRO_H
+#define CHIP8_LIBRETRO_H
+
+#include <stdbool.h>
+#include <stddef.h>
+#include <stdint.h>
+
+#define RETRO_API_VERSION 1
+
+#define RETRO_DEVICE_JOYPAD   1
+#define RETRO_DEVICE_KEYBOARD 3
+
+#define RETRO_DEVICE_ID_JOYPAD_B     0
+#define RETRO_DEVICE_ID_JOYPAD_Y     1
+#define RETRO_DEVICE_ID_JOYPAD_UP    4
+#define RETRO_DEVICE_ID_JOYPAD_DOWN  5
+#define RETRO_DEVICE_ID_JOYPAD_LEFT  6
+#define RETRO_DEVICE_ID_JOYPAD_RIGHT 7
+#define RETRO_DEVICE_ID_JOYPAD_A     8
+#define RETRO_DEVICE_ID_JOYPAD_X     9
+
+#define RETRO_REGION_NTSC 0
+
+#define RETRO_ENVIRONMENT_SET_PIXEL_FORMAT 10
+#define RETRO_ENVIRONMENT_SET_SUPPORT_NO_GAME 18
+
+enum retro_pixel_format {
+	RETRO_PIXEL_FORMAT_0RGB1555 = 0,
+	RETRO_PIXEL_FORMAT_XRGB8888 = 1,
+	RETRO_PIXEL_FORMAT_RGB565 = 2
+};
+
+struct retro_system_info {
+	const char *library_name;
+	const char *library_version;
+	const char *valid_extensions;
+	bool need_fullpath;
+	bool block_extract;
+};
+
+struct retro_game_geometry {
+	unsigned base_width;
+	unsigned base_height;
+	unsigned max_width;
+	unsigned max_height;
+	float aspect_ratio;
+};
+
+struct retro_system_timing {
+	double fps;
+	double sample_rate;
+};
+
+struct retro_system_av_info {
+	struct retro_game_geometry geometry;
+	struct retro_system_timing timing;
+};
+
+struct retro_game_info {
+	const char *path;
+	const void *data;
+	size_t size;
+	const char *meta;
+};
+
+typedef bool (*retro_environment_t)(unsigned cmd, void *data);
+typedef void (*retro_video_refresh_t)(const void *data, unsigned width, unsigned height, size_t pitch);
+typedef void (*retro_audio_sample_t)(int16_t left, int16_t right);
+typedef size_t (*retro_audio_sample_batch_t)(const int16_t *data, size_t frames);
+typedef void (*retro_input_poll_t)(void);
+typedef int16_t (*retro_input_state_t)(unsigned port, unsigned device, unsigned index, unsigned id);
+
+#endif
@@ -0,0 +1,219 @@
+/* A minimal libretro frontend that checks a core without a window:
+ *
+ *	go build -tags nosdl -buildmode=c-shared -o chip8_libretro.so ./libretro
+ *	cc -o testhost libretro/testhost/host.c -ldl
+ *	./testhost ./chip8_libretro.so
+ *
+ * It runs built-in roms, checks video, audio, input and save states, and
+ * exits non-zero on the first failure. */
+#include <dlfcn.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include "../libretro.h"
+
+/* ST=3C, then forever: CLS, draw the digit V0 at (V1,V2) while key A
+ * (RETROK_v) is down, V0 += 1 */
+static const unsigned char rom[] = {
+	0x6a, 0x3c, /* 200 LD   VA,#3C    */
+	0xfa, 0x18, /* 202 LD   ST,VA     */
+	0x64, 0x0a, /* 204 LD   V4,#0A    */
+	0x00, 0xe0, /* 206 CLS            */
+	0xf0, 0x29, /* 208 LD   F,V0      */
+	0xe4, 0xa1, /* 20A SKNP V4        */
+	0xd1, 0x25, /* 20C DRW  V1,V2,5   */
+	0x70, 0x01, /* 20E ADD  V0,#01    */
+	0x12, 0x06, /* 210 JP   206       */
+};
+
+/* CALL 200 forever, so that a state with a broken stack is used at once */
+static const unsigned char recurse[] = {
+	0x22, 0x00, /* 200 CALL 200       */
+};
+
+static unsigned width, height, lit, pixel_format;
+static size_t audio_frames;
+static int loud, keys_down;
+
+static bool environment(unsigned cmd, void *data) {
+	if (cmd == RETRO_ENVIRONMENT_SET_PIXEL_FORMAT) {
+		pixel_format = *(enum retro_pixel_format *)data;
+		return true;
+	}
+	return false;
+}
+
+static void video_refresh(const void *data, unsigned w, unsigned h, size_t pitch) {
+	const unsigned char *row = data;
+	width = w, height = h, lit = 0;
+	for (unsigned y = 0; y < h; y++, row += pitch) {
+		for (unsigned x = 0; x < w; x++) {
+			lit += ((const unsigned *)row)[x] != 0;
+		}
+	}
+}
+
+static void audio_sample(int16_t left, int16_t right) {}
+
+static size_t audio_sample_batch(const int16_t *data, size_t frames) {
+	audio_frames += frames;
+	for (size_t i = 0; i < 2 * frames; i++) {
+		loud |= data[i] != 0;
+	}
+	return frames;
+}
+
+static void input_poll(void) {}
+
+static int16_t input_state(unsigned port, unsigned device, unsigned index, unsigned id) {
+	return keys_down && device == RETRO_DEVICE_KEYBOARD && id == 'v';
+}
+
+static void *core;
+
+static void *sym(const char *name) {
+	void *f = dlsym(core, name);
+	if (!f) {
+		fprintf(stderr, "missing %s\n", name);
+		exit(1);
+	}
+	return f;
+}
+
+#define CHECK(cond) do { if (!(cond)) { fprintf(stderr, "FAIL %s:%d: %s\n", __FILE__, __LINE__, #cond); return 1; } } while (0)
+
+int main(int argc, char **argv) {
+	if (argc != 2) {
+		fprintf(stderr, "usage: %s core.so\n", argv[0]);
+		return 2;
+	}
+	core = dlopen(argv[1], RTLD_NOW);
+	if (!core) {
+		fprintf(stderr, "%s\n", dlerror());
+		return 1;
+	}
+
+	unsigned (*api_version)(void) = sym("retro_api_version");
+	void (*set_environment)(retro_environment_t) = sym("retro_set_environment");
+	void (*set_video_refresh)(retro_video_refresh_t) = sym("retro_set_video_refresh");
+	void (*set_audio_sample)(retro_audio_sample_t) = sym("retro_set_audio_sample");
+	void (*set_audio_sample_batch)(retro_audio_sample_batch_t) = sym("retro_set_audio_sample_batch");
+	void (*set_input_poll)(retro_input_poll_t) = sym("retro_set_input_poll");
+	void (*set_input_state)(retro_input_state_t) = sym("retro_set_input_state");
+	void (*init)(void) = sym("retro_init");
+	void (*deinit)(void) = sym("retro_deinit");
+	void (*get_system_info)(struct retro_system_info *) = sym("retro_get_system_info");
+	void (*get_system_av_info)(struct retro_system_av_info *) = sym("retro_get_system_av_info");
+	bool (*load_game)(const struct retro_game_info *) = sym("retro_load_game");
+	void (*unload_game)(void) = sym("retro_unload_game");
+	void (*run)(void) = sym("retro_run");
+	void (*reset)(void) = sym("retro_reset");
+	size_t (*serialize_size)(void) = sym("retro_serialize_size");
+	bool (*serialize)(void *, size_t) = sym("retro_serialize");
+	bool (*unserialize)(const void *, size_t) = sym("retro_unserialize");
+
+	CHECK(api_version() == RETRO_API_VERSION);
+	set_environment(environment);
+	set_video_refresh(video_refresh);
+	set_audio_sample(audio_sample);
+	set_audio_sample_batch(audio_sample_batch);
+	set_input_poll(input_poll);
+	set_input_state(input_state);
+	init();
+
+	struct retro_system_info info;
+	get_system_info(&info);
+	printf("%s %s\n", info.library_name, info.library_version);
+	struct retro_system_av_info av;
+	get_system_av_info(&av);
+	CHECK(av.geometry.base_width == 64 && av.geometry.base_height == 32);
+
+	struct retro_game_info game = {"test.ch8", rom, sizeof(rom), NULL};
+	CHECK(load_game(&game));
+	CHECK(pixel_format == RETRO_PIXEL_FORMAT_XRGB8888);
+
+	/* nothing is drawn until key A is down */
+	unsigned seen = 0;
+	for (int i = 0; i < 5; i++) {
+		run();
+		seen += lit;
+	}
+	CHECK(width == 64 && height == 32);
+	CHECK(seen == 0);
+	CHECK(audio_frames == 5 * (size_t)(av.timing.sample_rate / av.timing.fps));
+	CHECK(loud);
+	keys_down = 1;
+	for (int i = 0; i < 5; i++) {
+		run();
+		seen += lit;
+	}
+	CHECK(seen > 0);
+
+	/* the same frames after a save state and a detour */
+	size_t size = serialize_size();
+	void *state = malloc(size);
+	CHECK(serialize(state, size));
+	unsigned want[10];
+	for (int i = 0; i < 10; i++) {
+		run();
+		want[i] = lit;
+	}
+	keys_down = 0;
+	for (int i = 0; i < 5; i++) {
+		run();
+	}
+	keys_down = 1;
+	CHECK(unserialize(state, size));
+	for (int i = 0; i < 10; i++) {
+		run();
+		CHECK(lit == want[i]);
+	}
+
+	/* the buzzer stops with the sound timer */
+	reset();
+	for (int i = 0; i < 70; i++) {
+		run();
+	}
+	loud = 0;
+	run();
+	CHECK(!loud);
+
+	/* broken states are refused, and no state crashes the core: try every
+	 * byte of a state set to 7F, which gob reads as a small number, so
+	 * that SP and the stack depth take values out of range */
+	unload_game();
+	struct retro_game_info game2 = {"recurse.ch8", recurse, sizeof(recurse), NULL};
+	CHECK(load_game(&game2));
+	run();
+	CHECK(serialize(state, size));
+	size_t n = ((unsigned char *)state)[0] | ((unsigned char *)state)[1] << 8 |
+		((unsigned char *)state)[2] << 16 | (size_t)((unsigned char *)state)[3] << 24;
+	CHECK(n + 4 <= size);
+	unsigned char *bad = malloc(size);
+	memcpy(bad, state, size);
+	bad[3] = 0xff;
+	CHECK(!unserialize(bad, size));
+	memcpy(bad, state, size);
+	memset(bad + 4, 0xff, n);
+	CHECK(!unserialize(bad, size));
+	CHECK(!unserialize(state, 3));
+	int refused = 0;
+	for (size_t i = 4; i < 4 + n; i++) {
+		memcpy(bad, state, size);
+		bad[i] = 0x7f;
+		if (!unserialize(bad, size)) {
+			refused++;
+		}
+		run();
+	}
+	CHECK(refused > 0);
+	CHECK(unserialize(state, size));
+	free(bad);
+
+	unload_game();
+	deinit();
+	free(state);
+	printf("ok\n");
+	return 0;
+}
@@ -3,25 +3,213 @@ package main
 import (
 	"flag"
 	"io"
+	"log"
 	"os"
+	"os/signal"
 	"runtime"
+	"syscall"
 
 	e "github.com/tuboc/chip8/emulator"
 )
 
 var filename = flag.String("f", "", "chip8 image file path")
 var stepMode = flag.Bool("s", false, "start with stepMode")
+var frontendName = flag.String("frontend", "sdl", "frontend (sdl, tty, null)")
+var serve = flag.String("serve", "", "serve the emulator to a browser on this address, e.g. :8080, instead of a frontend")
+var servePublic = flag.Bool("serve-public", false, "allow -serve on addresses other machines can reach; anyone who opens the page can play")
+var maxFrames = flag.Int("frames", 0, "quit after this many frames (0: run until closed)")
+var quirks = flag.String("quirks", "default", "quirks preset (default, vip, schip, xochip)")
+var tracePath = flag.String("trace", "", "write every executed instruction to this file")
+var traceFormat = flag.String("trace-format", e.TraceText, "trace file format (text, binary)")
+var traceRange = flag.String("trace-range", "000-fff", "only trace instructions in this address range")
+var traceLimit = flag.Int64("trace-limit", 0, "rotate the trace file to <file>.1 when it exceeds this many bytes")
+var profilePath = flag.String("profile", "", "write a pprof profile of the executed instructions to this file on exit")
+var coverPath = flag.String("cover", "", "merge the executed addresses into this coverage file on exit")
+var heatmapPath = flag.String("heatmap", "", "write a PNG heatmap of the memory accesses to this file on exit")
+var symPath = flag.String("sym", "", "symbol file (default: the rom path with a .sym extension, if present)")
+var breakpoints = flag.String("break", "", "comma separated addresses or labels to enter stepMode at")
+var console = flag.Bool("console", false, "read debugger commands from the terminal (type help)")
+var debugScript = flag.String("debug-script", "", "run the debugger commands in this file at startup")
+var screenshotAt = flag.Int("screenshot-at-frame", 0, "write a screenshot after this many frames")
+var screenshotPath = flag.String("screenshot", "screenshot.png", "file for -screenshot-at-frame")
+var screenshotScale = flag.Int("screenshot-scale", e.DisplayScale, "size of a chip8 pixel in screenshots")
+var palette = flag.String("palette", "classic", "display colors: a theme (classic, amber, white, lcd) or RRGGBB,RRGGBB[,RRGGBB,RRGGBB] (foreground, background, XO-CHIP plane 2, both planes)")
+var paletteFile = flag.String("palette-file", "", "read the display colors from this file instead of -palette")
+var screenshotPalette = flag.String("screenshot-palette", "", "screenshot and recording colors like -palette, default the display colors")
+var screenshotPanel = flag.Bool("screenshot-panel", false, "draw the registers below the display in screenshots")
+var recordPath = flag.String("record", "", "record the display from the start to this file, - for stdout")
+var recordFormat = flag.String("record-format", "", "recording format (gif, y4m, ppm), default from the -record extension, y4m on stdout")
+var recordScale = flag.Int("record-scale", 4, "size of a chip8 pixel in recordings")
+var recordWAV = flag.Bool("record-wav", false, "also record the beep to a WAV file named like the recording")
+var toneFreq = flag.Float64("tone-freq", e.DefaultSynth.Frequency, "buzzer frequency in Hz")
+var toneVolume = flag.Float64("tone-volume", e.DefaultSynth.Volume, "buzzer volume, 0-1")
+var toneWave = flag.String("tone-wave", "square", "buzzer waveform (square, sine)")
+var audioLatency = flag.Duration("audio-latency", e.DefaultSynth.Latency, "most sound queued ahead of the speaker, at least one device buffer and a frame")
+var wavPath = flag.String("wav", "", "render the buzzer to this WAV file instead of playing it")
+var wavRate = flag.Int("wav-rate", e.WAVSampleRate, "sample rate of -wav")
+var breakStackFault = flag.Bool("break-stack-fault", false, "enter stepMode on CALL with a full stack or RET with an empty one")
+
+// frontends opens the frontends selectable with -frontend. main_sdl.go adds
+// sdl unless built with the nosdl tag.
+var frontends = map[string]func() e.Frontend{
+	"tty":  e.NewTerminalFrontend,
+	"null": e.NullFrontend,
+}
 
 func init() {
 	runtime.LockOSThread()
 }
 
+// displayPalette is the palette of -palette-file, or else -palette.
+func displayPalette() e.Palette {
+	var p e.Palette
+	var err error
+	if *paletteFile != "" {
+		p, err = e.LoadPalette(*paletteFile)
+	} else {
+		p, err = e.ParsePalette(*palette)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	return p
+}
+
+// synthOptions is the buzzer of the -tone flags.
+func synthOptions() e.SynthOptions {
+	o := e.DefaultSynth
+	w, err := e.ParseWaveform(*toneWave)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *toneFreq <= 0 || *toneVolume < 0 || *toneVolume > 1 {
+		log.Fatal("-tone-freq must be positive and -tone-volume within 0-1")
+	}
+	if *audioLatency < 0 {
+		log.Fatal("-audio-latency must not be negative")
+	}
+	o.Wave, o.Frequency, o.Volume, o.Latency = w, *toneFreq, *toneVolume, *audioLatency
+	return o
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Parse()
 
 	f, _ := os.Open(*filename)
 	binary, _ := io.ReadAll(f)
 
-	emu := e.NewEmulator(binary, *stepMode)
+	var fe e.Frontend
+	if *serve != "" {
+		var err error
+		if fe, err = e.NewWebFrontend(*serve, *servePublic); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		open, ok := frontends[*frontendName]
+		if !ok {
+			log.Fatalf("unknown frontend %q", *frontendName)
+		}
+		if *console && *frontendName == "tty" {
+			log.Fatal("-console needs a frontend that does not read the terminal")
+		}
+		if *recordPath == "-" && *frontendName == "tty" {
+			log.Fatal("-record - needs a frontend that does not draw on stdout")
+		}
+		fe = open()
+	}
+	if *wavPath != "" {
+		if *wavRate <= 0 {
+			log.Fatal("-wav-rate must be positive")
+		}
+		o := synthOptions()
+		o.SampleRate = *wavRate
+		a, err := e.NewWAVAudio(*wavPath, o)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fe.Audio = a
+	}
+	emu := e.NewEmulator(binary, *stepMode, lookupQuirks(*quirks), fe)
+	emu.SetMaxFrames(*maxFrames)
+	emu.SetPalette(displayPalette())
+	shot := e.ScreenshotOptions{Scale: *screenshotScale, Panel: *screenshotPanel}
+	if *screenshotPalette != "" {
+		p, err := e.ParsePalette(*screenshotPalette)
+		if err != nil {
+			log.Fatal(err)
+		}
+		shot.Palette = p
+	}
+	emu.SetScreenshot(shot)
+	emu.ScreenshotAt(*screenshotAt, *screenshotPath)
+	emu.SetSynth(synthOptions())
+	emu.SetRecording(e.RecordOptions{Format: *recordFormat, Scale: *recordScale, Palette: shot.Palette, Beep: *recordWAV})
+	if *recordPath != "" {
+		if err := emu.Record(*recordPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	symbols := loadSymbols(*symPath, *filename)
+	emu.SetSymbols(symbols)
+	emu.SetBreakOnStackFault(*breakStackFault)
+	emu.SetBreakpoints(parseAddresses(*breakpoints, symbols))
+	if *tracePath != "" {
+		t, err := e.NewTracer(*tracePath, *traceFormat)
+		if err != nil {
+			log.Fatal(err)
+		}
+		t.From, t.To = parseRange(*traceRange)
+		t.Limit = *traceLimit
+		t.Symbols = symbols
+		emu.SetTracer(t)
+		defer func() {
+			if err := t.Close(); err != nil {
+				log.Print(err)
+			}
+		}()
+	}
+	if *profilePath != "" {
+		p := e.NewProfiler()
+		emu.SetProfiler(p)
+		defer writeProfile(p, *profilePath, *filename)
+	}
+	if *coverPath != "" {
+		cv := &e.Coverage{}
+		emu.SetCoverage(cv)
+		defer func() {
+			if err := e.MergeCoverageFile(*coverPath, cv); err != nil {
+				log.Print(err)
+			}
+		}()
+	}
+	if *heatmapPath != "" {
+		h := &e.Heatmap{}
+		emu.SetHeatmap(h)
+		defer writeHeatmap(h, *heatmapPath)
+	}
+	if *debugScript != "" {
+		if err := emu.RunScript(*debugScript); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *console {
+		emu.StartConsole()
+	}
+	// end the run like closing the window, so recordings, -wav and the
+	// files written on exit are complete; a second signal kills
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		signal.Stop(sig)
+		emu.Quit()
+	}()
 	emu.Run()
 }
@@ -0,0 +1,10 @@
+//go:build !nosdl && !js
+// +build !nosdl,!js
+
+package main
+
+import e "github.com/tuboc/chip8/emulator"
+
+func init() {
+	frontends["sdl"] = func() e.Frontend { return e.NewSDLFrontend(synthOptions()) }
+}
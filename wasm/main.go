@@ -0,0 +1,13 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command wasm is the emulator core for web pages, see index.html.
+package main
+
+import e "github.com/tuboc/chip8/emulator"
+
+func main() {
+	e.RegisterJS("chip8")
+	// keep the functions alive for the page
+	select {}
+}